@@ -3,11 +3,13 @@ set -e
 
 gitVersion=$(git describe)
 gitCommit=$(git rev-parse HEAD)
+gitTreeState=$(test -z "$(git status --porcelain)" && echo clean || echo dirty)
 buildDate=$(date -u +'%Y-%m-%dT%H:%M:%SZ')
 ldflags="\
--X 'k8s.io/client-go/pkg/version.gitVersion=$gitVersion' \
--X 'k8s.io/client-go/pkg/version.gitCommit=$gitCommit' \
--X 'k8s.io/client-go/pkg/version.buildDate=$buildDate' \
+-X 'github.com/qqbuby/kconfig/cmd/version.gitVersion=$gitVersion' \
+-X 'github.com/qqbuby/kconfig/cmd/version.gitCommit=$gitCommit' \
+-X 'github.com/qqbuby/kconfig/cmd/version.gitTreeState=$gitTreeState' \
+-X 'github.com/qqbuby/kconfig/cmd/version.buildDate=$buildDate' \
 "
 
 go build -ldflags="$ldflags"
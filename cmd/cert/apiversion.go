@@ -0,0 +1,39 @@
+package cert
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/runtime/serializer/versioning"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
+)
+
+const flagConfigAPIVersion = "config-api-version"
+
+func (o *CertOptions) validateConfigAPIVersion() error {
+	if !contains(clientcmdlatest.Versions, o.configVersion) {
+		return fmt.Errorf("unsupported --%s %q, must be one of %q", flagConfigAPIVersion, o.configVersion, clientcmdlatest.Versions)
+	}
+	// clientcmd.ModifyConfig always writes the latest version
+	if o.merge && len(o.mergeInto) == 0 && o.configVersion != clientcmdlatest.Version {
+		return fmt.Errorf("--%s %s requires --%s when merging, the current kubeconfig is written as %s", flagConfigAPIVersion, o.configVersion, flagMergeInto, clientcmdlatest.Version)
+	}
+	return nil
+}
+
+// encodeKubeconfig serializes the kubeconfig as the version of the config
+// api, where clientcmd.Write always uses the latest one.
+func encodeKubeconfig(config clientcmdapi.Config, version string) ([]byte, error) {
+	yamlSerializer := json.NewYAMLSerializer(json.DefaultMetaFactory, clientcmdlatest.Scheme, clientcmdlatest.Scheme)
+	codec := versioning.NewDefaultingCodecForScheme(
+		clientcmdlatest.Scheme,
+		yamlSerializer,
+		yamlSerializer,
+		schema.GroupVersion{Version: version},
+		runtime.InternalGroupVersioner,
+	)
+	return runtime.Encode(codec, &config)
+}
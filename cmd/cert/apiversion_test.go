@@ -0,0 +1,48 @@
+package cert
+
+import (
+	"os"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestConfigAPIVersion(t *testing.T) {
+	var tests = []struct {
+		version string
+		err     bool
+	}{
+		{version: "v1"},
+		{version: "v2", err: true},
+		{version: "", err: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.configVersion = test.version
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%q: unexpected error %v", test.version, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if err := o.writeKubeconfig([]byte("key"), []byte("certificate")); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(o.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var config struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+		}
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			t.Fatal(err)
+		}
+		if config.APIVersion != test.version || config.Kind != "Config" {
+			t.Errorf("%q: got %s %s", test.version, config.APIVersion, config.Kind)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const (
+	flagPolicyFile = "policy-file"
+)
+
+// approverPolicy lists what the approver may approve, an empty list of
+// users or groups allows any, though not both, and an empty list of signers
+// allows the kube-apiserver-client signer only.
+type approverPolicy struct {
+	Users   []string `json:"users,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Signers []string `json:"signers,omitempty"`
+}
+
+type ApproverOptions struct {
+	clientSet      clientset.Interface
+	policyFile     string
+	policy         approverPolicy
+	approveReason  string
+	approveMessage string
+	creator        string
+}
+
+func NewCmdCertApprover(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	o := ApproverOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "approver",
+		Short: "Watch the csrs created by kconfig and approve those allowed by a policy.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(configFlags))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.policyFile, flagPolicyFile, "", "yaml file with the users, groups and signers that may be approved")
+	cmd.MarkFlagRequired(flagPolicyFile)
+	addCreatorFlag(cmd, &o.creator)
+	cmd.Flags().StringVar(&o.approveReason, flagApproveReason, ReasonKconfigCertApprove, "reason of the csr approval condition")
+	cmd.Flags().StringVar(&o.approveMessage, flagApproveMessage, defaultApproveMessage, "message of the csr approval condition")
+
+	return cmd
+}
+
+func (o *ApproverOptions) Complete(configFlags *genericclioptions.ConfigFlags) error {
+	content, err := os.ReadFile(o.policyFile)
+	if err != nil {
+		return err
+	}
+	if err := yaml.UnmarshalStrict(content, &o.policy); err != nil {
+		return fmt.Errorf("--%s: %v", flagPolicyFile, err)
+	}
+	if len(o.policy.Signers) == 0 {
+		o.policy.Signers = []string{certificatesv1.KubeAPIServerClientSignerName}
+	}
+
+	restConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	o.clientSet, err = clientset.NewForConfig(restConfig)
+	return err
+}
+
+func (o *ApproverOptions) Validate() error {
+	if err := validateCreator(o.creator); err != nil {
+		return err
+	}
+	if len(o.approveReason) == 0 {
+		return fmt.Errorf("--%s must not be empty", flagApproveReason)
+	}
+	// a policy without users and groups would approve any identity
+	if len(o.policy.Users) == 0 && len(o.policy.Groups) == 0 {
+		return fmt.Errorf("--%s must list users or groups", flagPolicyFile)
+	}
+	return nil
+}
+
+// Run approves the pending kconfig csrs until SIGINT or SIGTERM.
+func (o *ApproverOptions) Run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := o.approve(ctx)
+	if ctx.Err() != nil {
+		klog.InfoS("approver stopped")
+		return nil
+	}
+	return err
+}
+
+func (o *ApproverOptions) approve(ctx context.Context) error {
+	options := metav1.ListOptions{
+		LabelSelector: creatorSelector(o.creator),
+	}
+
+	for {
+		list, err := o.clientSet.CertificatesV1().
+			CertificateSigningRequests().
+			List(ctx, options)
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			o.approveIfAllowed(ctx, &list.Items[i])
+		}
+
+		options.ResourceVersion = list.ResourceVersion
+		w, err := o.clientSet.CertificatesV1().
+			CertificateSigningRequests().
+			Watch(ctx, options)
+		if err != nil {
+			return err
+		}
+		err = o.watch(ctx, w)
+		w.Stop()
+		if err != nil && !apierrors.IsResourceExpired(err) && !apierrors.IsGone(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// watch approves the csrs added or modified until the watch closes.
+func (o *ApproverOptions) watch(ctx context.Context, w watch.Interface) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+
+			switch event.Type {
+			case watch.Error:
+				return apierrors.FromObject(event.Object)
+			case watch.Added, watch.Modified:
+				if csr, ok := event.Object.(*certificatesv1.CertificateSigningRequest); ok {
+					o.approveIfAllowed(ctx, csr)
+				}
+			}
+		}
+	}
+}
+
+// approveIfAllowed approves the csr if the policy allows its subject. The
+// creator annotation only scopes the csrs looked at, any client can set it.
+func (o *ApproverOptions) approveIfAllowed(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) {
+	if !isCreator(csr.Annotations[annotationCreator], o.creator) || !isPending(csr) {
+		return
+	}
+	if err := o.policy.allows(csr); err != nil {
+		klog.InfoS("skip csr", "csr", csr.Name, "reason", err)
+		return
+	}
+
+	csr.Status.Conditions = append(csr.Status.Conditions, approvalCondition(o.approveReason, o.approveMessage))
+	_, err := o.clientSet.CertificatesV1().
+		CertificateSigningRequests().
+		UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{})
+	if err != nil {
+		klog.ErrorS(err, "approve csr", "csr", csr.Name)
+		return
+	}
+	klog.InfoS("approved csr", "csr", csr.Name, "requester", csr.Spec.Username)
+}
+
+// allows checks the identity the certificate will carry, the common name and
+// organizations of the requested subject. The spec username and groups are
+// those of the requester, not of the certificate. Reserved system:
+// identities are never allowed.
+func (p approverPolicy) allows(csr *certificatesv1.CertificateSigningRequest) error {
+	if !contains(p.Signers, csr.Spec.SignerName) {
+		return fmt.Errorf("signer %q not allowed", csr.Spec.SignerName)
+	}
+
+	request, err := cmdutilpkix.ParseCertificateRequestPem(csr.Spec.Request)
+	if err != nil {
+		return fmt.Errorf("invalid request: %v", err)
+	}
+	if err := request.CheckSignature(); err != nil {
+		return fmt.Errorf("invalid request signature: %v", err)
+	}
+
+	user, groups := request.Subject.CommonName, request.Subject.Organization
+	if identities := systemIdentities(user, groups); len(identities) != 0 {
+		return fmt.Errorf("reserved identities %q not allowed", identities)
+	}
+	if len(p.Users) != 0 && !contains(p.Users, user) {
+		return fmt.Errorf("user %q not allowed", user)
+	}
+	for _, group := range groups {
+		if len(p.Groups) != 0 && !contains(p.Groups, group) {
+			return fmt.Errorf("group %q not allowed", group)
+		}
+	}
+	return nil
+}
+
+func isPending(csr *certificatesv1.CertificateSigningRequest) bool {
+	return len(csr.Status.Conditions) == 0 && len(csr.Status.Certificate) == 0
+}
@@ -0,0 +1,110 @@
+package cert
+
+import (
+	"context"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+func TestValidateApprover(t *testing.T) {
+	var tests = []struct {
+		name   string
+		policy approverPolicy
+		err    bool
+	}{
+		{name: "users", policy: approverPolicy{Users: []string{"alice"}}},
+		{name: "groups", policy: approverPolicy{Groups: []string{"dev"}}},
+		{name: "signers only", policy: approverPolicy{Signers: []string{certificatesv1.KubeAPIServerClientSignerName}}, err: true},
+		{name: "empty", err: true},
+	}
+	for _, test := range tests {
+		o := ApproverOptions{policy: test.policy, approveReason: ReasonKconfigCertApprove, creator: creatorKconfig}
+		if err := o.Validate(); test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestApproveIfAllowed(t *testing.T) {
+	policy := approverPolicy{
+		Users:   []string{"alice"},
+		Groups:  []string{"dev"},
+		Signers: []string{certificatesv1.KubeAPIServerClientSignerName},
+	}
+
+	var tests = []struct {
+		name      string
+		policy    *approverPolicy
+		creator   string
+		requester string
+		user      string
+		groups    []string
+		signer    string
+		request   []byte
+		approved  bool
+	}{
+		{name: "allowed", creator: creatorKconfig, requester: "admin", user: "alice", groups: []string{"dev"}, signer: certificatesv1.KubeAPIServerClientSignerName, approved: true},
+		{name: "custom creator", creator: "team-a.example.com", requester: "admin", user: "alice", groups: []string{"dev"}, signer: certificatesv1.KubeAPIServerClientSignerName, approved: true},
+		{name: "other creator", creator: "team-b.example.com", requester: "admin", user: "alice", groups: []string{"dev"}, signer: certificatesv1.KubeAPIServerClientSignerName},
+		{name: "not kconfig", requester: "admin", user: "alice", groups: []string{"dev"}, signer: certificatesv1.KubeAPIServerClientSignerName},
+		{name: "user", creator: creatorKconfig, requester: "alice", user: "mallory", groups: []string{"dev"}, signer: certificatesv1.KubeAPIServerClientSignerName},
+		{name: "group", creator: creatorKconfig, requester: "alice", user: "alice", groups: []string{"system:masters"}, signer: certificatesv1.KubeAPIServerClientSignerName},
+		{name: "signer", creator: creatorKconfig, requester: "alice", user: "alice", groups: []string{"dev"}, signer: certificatesv1.KubeletServingSignerName},
+		{name: "any user reserved group", policy: &approverPolicy{Signers: policy.Signers}, creator: creatorKconfig, requester: "alice", user: "alice", groups: []string{"system:masters"}, signer: certificatesv1.KubeAPIServerClientSignerName},
+		{name: "any user reserved user", policy: &approverPolicy{Signers: policy.Signers}, creator: creatorKconfig, requester: "alice", user: "system:kube-controller-manager", signer: certificatesv1.KubeAPIServerClientSignerName},
+		{name: "invalid request", creator: creatorKconfig, requester: "alice", signer: certificatesv1.KubeAPIServerClientSignerName, request: []byte("request")},
+	}
+	for _, test := range tests {
+		request := test.request
+		if request == nil {
+			_, der, err := cmdutilpkix.CreateDefaultCertificateRequest(test.user, test.groups, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			request, err = cmdutilpkix.PemCertificateRequest(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the spec carries the requester, who may be allowed by the policy
+		csr := &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "csr",
+				Annotations: map[string]string{annotationCreator: test.creator},
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Username:   test.requester,
+				Groups:     []string{"dev"},
+				SignerName: test.signer,
+				Request:    request,
+			},
+		}
+		client := fake.NewSimpleClientset(csr.DeepCopy())
+		o := ApproverOptions{
+			clientSet:      client,
+			policy:         policy,
+			approveReason:  ReasonKconfigCertApprove,
+			approveMessage: defaultApproveMessage,
+			creator:        "team-a.example.com",
+		}
+		if test.policy != nil {
+			o.policy = *test.policy
+		}
+
+		o.approveIfAllowed(context.TODO(), csr)
+
+		got, err := client.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), "csr", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		approved := len(got.Status.Conditions) == 1 && IsKconfigApproval(got.Status.Conditions[0])
+		if approved != test.approved {
+			t.Errorf("%s: approved %v, want %v", test.name, approved, test.approved)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package cert
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const (
+	flagKey         = "key"
+	flagCert        = "cert"
+	flagCA          = "ca"
+	flagServer      = "server"
+	flagClusterName = "cluster-name"
+
+	defaultClusterName = "kubernetes"
+)
+
+type AssembleOptions struct {
+	keyFile     string
+	certFile    string
+	caFile      string
+	server      string
+	clusterName string
+	userName    string
+	output      string
+
+	key  []byte
+	cert []byte
+	ca   []byte
+
+	out io.Writer
+}
+
+func NewCmdCertAssemble() *cobra.Command {
+	o := AssembleOptions{
+		out: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "assemble",
+		Short: "Create kubeconfig file from an existing private key and signed certificate.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete())
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.keyFile, flagKey, "", "private key file")
+	cmd.MarkFlagRequired(flagKey)
+	cmd.Flags().StringVar(&o.certFile, flagCert, "", "client certificate file")
+	cmd.MarkFlagRequired(flagCert)
+	cmd.Flags().StringVar(&o.caFile, flagCA, "", "cluster certificate authority file")
+	cmd.MarkFlagRequired(flagCA)
+	cmd.Flags().StringVar(&o.server, flagServer, "", "cluster api server address")
+	cmd.MarkFlagRequired(flagServer)
+	cmd.Flags().StringVarP(&o.userName, flagUserName, "u", "", "user name")
+	cmd.MarkFlagRequired(flagUserName)
+	cmd.Flags().StringVar(&o.clusterName, flagClusterName, defaultClusterName, "cluster name")
+	cmd.Flags().StringVarP(&o.output, flagOutput, "o", "", "output file - default stdout")
+
+	return cmd
+}
+
+func (o *AssembleOptions) Complete() error {
+	var err error
+	o.key, err = os.ReadFile(o.keyFile)
+	if err != nil {
+		return err
+	}
+	o.cert, err = os.ReadFile(o.certFile)
+	if err != nil {
+		return err
+	}
+	o.ca, err = os.ReadFile(o.caFile)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o *AssembleOptions) Validate() error {
+	if u, err := url.Parse(o.server); err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+		return fmt.Errorf("--%s must be an https url, got %q", flagServer, o.server)
+	}
+
+	key, err := cmdutilpkix.ParsePrivateKeyPem(o.key)
+	if err != nil {
+		return fmt.Errorf("%s: %v", o.keyFile, err)
+	}
+
+	certs, err := cmdutilpkix.ParseCertificatesPem(o.cert)
+	if err != nil {
+		return fmt.Errorf("%s: %v", o.certFile, err)
+	}
+
+	if _, err := cmdutilpkix.ParseCertificatesPem(o.ca); err != nil {
+		return fmt.Errorf("%s: %v", o.caFile, err)
+	}
+
+	if !cmdutilpkix.PublicKeyEqual(key.Public(), certs[0].PublicKey) {
+		return fmt.Errorf("private key %s does not match certificate %s", o.keyFile, o.certFile)
+	}
+
+	return nil
+}
+
+func (o *AssembleOptions) Run() error {
+	cluster := &clientcmdapi.Cluster{
+		Server:                   o.server,
+		CertificateAuthorityData: o.ca,
+	}
+
+	kubeconfig := newKubeconfig(o.clusterName, cluster, o.userName, DefaultNamespace, o.key, o.cert)
+	return writeValidatedKubeconfig(kubeconfig, o.out, o.output, keyFileMode)
+}
@@ -0,0 +1,149 @@
+package cert
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const (
+	auditFileMode = 0600
+
+	auditFormatJSON = "json"
+	auditFormatText = "text"
+)
+
+type auditRecord struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Username   string    `json:"username"`
+	Groups     []string  `json:"groups"`
+	CSRName    string    `json:"csrName"`
+	SignerName string    `json:"signerName"`
+	Serial     string    `json:"serial,omitempty"`
+	Expiry     string    `json:"expiry,omitempty"`
+	Operator   string    `json:"operator,omitempty"`
+}
+
+func validateAuditFormat(format string) error {
+	switch format {
+	case auditFormatJSON, auditFormatText:
+		return nil
+	default:
+		return fmt.Errorf("--%s must be '%s' or '%s', got %q", flagAuditFormat, auditFormatJSON, auditFormatText, format)
+	}
+}
+
+// writeAudit writes the audit record of an issued certificate to --audit-out,
+// a text record without it goes to stderr.
+func (o *CertOptions) writeAudit(cert []byte) error {
+	record := auditRecord{
+		Timestamp:  time.Now().UTC(),
+		Username:   o.userName,
+		Groups:     o.groups,
+		CSRName:    o.csrName,
+		SignerName: o.signerName,
+		Operator:   o.operator(),
+	}
+	if certs, err := cmdutilpkix.ParseCertificatesPem(cert); err == nil {
+		record.Serial = serialHex(certs[0])
+		record.Expiry = certs[0].NotAfter.UTC().Format(time.RFC3339)
+	}
+
+	if o.auditFormat == auditFormatText {
+		content := record.text()
+		if len(o.auditOut) == 0 {
+			_, err := fmt.Fprint(o.errOut, content)
+			return err
+		}
+		return cmdutil.WriteFile(o.auditOut, []byte(content), auditFileMode)
+	}
+
+	content, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return cmdutil.WriteFile(o.auditOut, append(content, '\n'), auditFileMode)
+}
+
+// text returns the record as a short block for change tickets.
+func (r auditRecord) text() string {
+	none := func(s string) string {
+		if len(s) == 0 {
+			return "<none>"
+		}
+		return s
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Client certificate issued at %s\n", r.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(&b, "  User:     %s\n", r.Username)
+	fmt.Fprintf(&b, "  Groups:   %s\n", none(strings.Join(r.Groups, ", ")))
+	fmt.Fprintf(&b, "  CSR:      %s\n", r.CSRName)
+	fmt.Fprintf(&b, "  Signer:   %s\n", r.SignerName)
+	fmt.Fprintf(&b, "  Serial:   %s\n", none(r.Serial))
+	fmt.Fprintf(&b, "  Expiry:   %s\n", none(r.Expiry))
+	fmt.Fprintf(&b, "  Operator: %s\n", none(r.Operator))
+	return b.String()
+}
+
+// certificateSerial returns the hex serial number of the first PEM
+// certificate.
+func certificateSerial(cert []byte) (string, error) {
+	certs, err := cmdutilpkix.ParseCertificatesPem(cert)
+	if err != nil {
+		return "", err
+	}
+	return serialHex(certs[0]), nil
+}
+
+func serialHex(cert *x509.Certificate) string {
+	return cert.SerialNumber.Text(16)
+}
+
+// operator returns the user kconfig talks to the cluster as, the user of the
+// current kubeconfig context unless the rest config names one.
+func (o *CertOptions) operator() string {
+	if o.restConfig != nil && len(o.restConfig.Username) != 0 {
+		return o.restConfig.Username
+	}
+	if o.inCluster || len(o.rawServer) != 0 {
+		return ""
+	}
+
+	startingConfig, err := loadStartingConfig(o.configAccess)
+	if err != nil {
+		return ""
+	}
+	if ctx, ok := startingConfig.Contexts[startingConfig.CurrentContext]; ok {
+		return ctx.AuthInfo
+	}
+	return ""
+}
+
+// authenticatedOperator returns the user kconfig authenticates as, the
+// common name of its client certificate or its basic auth user, or nothing
+// for tokens and other credentials the identity cannot be read from.
+func (o *CertOptions) authenticatedOperator() string {
+	if o.restConfig == nil {
+		return ""
+	}
+
+	data := o.restConfig.TLSClientConfig.CertData
+	if len(data) == 0 && len(o.restConfig.TLSClientConfig.CertFile) != 0 {
+		data, _ = os.ReadFile(o.restConfig.TLSClientConfig.CertFile)
+	}
+	if len(data) != 0 {
+		if certs, err := cmdutilpkix.ParseCertificatesPem(data); err == nil {
+			return certs[0].Subject.CommonName
+		}
+	}
+
+	return o.restConfig.Username
+}
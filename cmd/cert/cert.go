@@ -1,22 +1,47 @@
 package cert
 
 import (
+	"bufio"
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/spf13/cobra"
 
 	certificatesv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/validation/path"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	clientcmdlatest "k8s.io/client-go/tools/clientcmd/api/latest"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/yaml"
 
 	cmdutil "github.com/qqbuby/kconfig/cmd/util"
 	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
@@ -28,161 +53,2293 @@ const (
 	flagExpiration = "expiration"
 	flagOutput     = "output"
 
+	flagWatchExisting  = "watch-existing"
+	flagDeleteExisting = "delete-existing"
+	flagKeyFile        = "key-file"
+	flagTimeout        = "timeout"
+	flagKeyType        = "key-type"
+	flagOffline        = "offline"
+	flagRequestOnly    = "request-only"
+	flagKeyOut         = "key-out"
+	flagCurve          = "curve"
+	flagInCluster      = "in-cluster"
+
+	flagForceRecreateOnDenied = "force-recreate-on-denied"
+
+	flagNoCreatorAnnotation = "no-creator-annotation"
+	flagConfirmApprove      = "confirm-approve"
+	flagMerge               = "merge"
+	flagOnConflict          = "on-conflict"
+	flagVerifyLogin         = "verify-login"
+	flagCSRNameTemplate     = "csr-name-template"
+	flagCNTemplate          = "cn-template"
+	flagStrictTLS           = "strict-tls"
+	flagCompact             = "compact"
+	flagEnv                 = "env"
+	flagNamespace           = "namespace"
+	flagGroupNamespace      = "group-namespace"
+	flagPostHook            = "post-hook"
+	flagIgnoreHookFailure   = "ignore-hook-failure"
+	flagSignerName          = "signer-name"
+	flagAllowUnknownSigner  = "allow-unknown-signer"
+	flagUsages              = "usages"
+	flagApproveReason       = "approve-reason"
+	flagApproveMessage      = "approve-message"
+	flagApproveMsgTemplate  = "approve-message-template"
+	flagMaxEvents           = "max-events"
+	flagRequestFrom         = "request-from"
+	flagOutputMode          = "output-mode"
+	flagCertOut             = "cert-out"
+	flagReferenceFiles      = "reference-files"
+	flagNoEmbedKey          = "no-embed-key"
+	flagKeyRefPath          = "key-ref-path"
+	flagDeleteGracePeriod   = "delete-grace-period"
+	flagValidateNamespace   = "validate-namespace"
+	flagAuditOut            = "audit-out"
+	flagAuditFormat         = "audit-format"
+	flagAllowedGroups       = "allowed-groups"
+	flagDryRun              = "dry-run"
+	flagCreator             = "creator"
+	flagPrintConfigPath     = "print-config-path"
+	flagAdditionalCluster   = "additional-cluster"
+	flagCSRLabel            = "csr-label"
+	flagOutputDir           = "output-dir"
+	flagFilenameTemplate    = "filename-template"
+	flagInferNamespace      = "infer-namespace"
+	flagSkipApproveSigner   = "skip-approve-for-signer"
+	flagRawServer           = "raw-server"
+	flagToken               = "token"
+	flagCAFile              = "ca-file"
+	flagConfigFile          = "config-file"
+	flagAllowExistingCtx    = "allow-existing-context"
+	flagKeyFormat           = "key-format"
+	flagSerialOut           = "serial-out"
+	flagAllowSystemIdentity = "allow-system-identities"
+	flagMaxExpiration       = "max-expiration"
+	flagClampExpiration     = "clamp-expiration"
+	flagFormat              = "format"
+	flagSecretName          = "secret-name"
+	flagSecretNamespace     = "secret-namespace"
+	flagMergeInto           = "merge-into"
+	flagFailOnExisting      = "fail-on-existing"
+	flagOutputOwner         = "output-owner"
+	flagOutputGroup         = "output-group"
+	flagPrintSubject        = "print-subject"
+	flagSubjectGroup        = "subject-group"
+	flagRequestProfile      = "request-profile"
+	flagDeleteOnSuccess     = "delete-on-success"
+	flagRecordOperator      = "record-operator"
+	flagDeniedRetries       = "denied-retries"
+	flagDeniedBackoff       = "denied-backoff"
+	flagNoCurrentContext    = "no-current-context"
+	flagYes                 = "yes"
+
+	systemIdentityPrefix = "system:"
+
+	annotationCreator = "creator"
+	labelCreator      = "creator"
+	creatorKconfig    = "kconfig.local.io"
+	labelCreatedBy    = "app.kubernetes.io/created-by"
+	createdByKconfig  = "kconfig"
+
+	// the canonical hint for signer profiles choosing serial allocation, key
+	// usages and validity
+	annotationRequestProfile = "kconfig.local.io/request-profile"
+	annotationIssuedBy       = "kconfig.local.io/issued-by"
+
+	envCompactKubeconfig = "KUBECONFIG_B64"
+	envCreator           = "KCONFIG_CREATOR"
+
+	defaultApproveMessage = "This CSR was approved by kconfig cert approve."
+
+	// ReasonKconfigCertApprove is the reason of the approval condition
+	// kconfig sets on the csrs it approves.
+	ReasonKconfigCertApprove = "KconfigCertApprove"
+	// reasonKonfigCertApprove is the misspelled reason used by earlier
+	// versions, still recognized as a kconfig approval.
+	reasonKonfigCertApprove = "KonfigCertApprove"
+
+	formatKubeconfig  = "kubeconfig"
+	formatSecret      = "secret"
+	formatRequestJSON = "request-json"
+
+	secretKeyKubeconfig = "kubeconfig"
+
+	dryRunNone   = "none"
+	dryRunClient = "client"
+	dryRunServer = "server"
+
+	// DefaultNamespace is the namespace of the emitted context when neither
+	// --namespace nor --group-namespace sets one.
+	DefaultNamespace = "default"
+
+	defaultFilenameTemplate = "{{.User}}.kubeconfig"
+
+	// maxCommonNameLength is the ub-common-name upper bound of rfc 5280.
+	maxCommonNameLength = 64
+
+	defaultOutputMode = "0600"
+	keyFileMode       = 0600
+	certFileMode      = 0644
+
 	expirationSeconds = 60 * 60 * 24 * 365 // one year in seconds
+	minExpiration     = 10 * time.Minute   // the minimum expirationSeconds of a csr
 )
 
-type CertOptions struct {
-	clientSet    clientset.Interface
-	configAccess clientcmd.ConfigAccess
-	csrName      string
-	userName     string
-	groups       []string
-	output       string
-}
+var (
+	inClusterConfig = rest.InClusterConfig
+
+	errCertificateDenied = errors.New("certificate signing request was denied")
+)
+
+type CertOptions struct {
+	clientSet    clientset.Interface
+	configAccess clientcmd.ConfigAccess
+	csrName      string
+	userName     string
+	groups       []string
+	output       string
+
+	watchExisting  bool
+	deleteExisting bool
+	keyFile        string
+	timeout        time.Duration
+	keyType        string
+	offline        bool
+	requestOnly    bool
+	keyOut         string
+	curve          string
+	curveSet       bool
+	inCluster      bool
+
+	forceRecreateOnDenied bool
+	noCreatorAnnotation   bool
+	confirmApprove        bool
+	yes                   bool
+	merge                 bool
+	onConflict            string
+	verifyLogin           bool
+	csrNameTemplate       string
+	cnTemplate            string
+	commonName            string
+	strictTLS             bool
+	compact               bool
+	env                   bool
+	namespace             string
+	groupNamespaces       map[string]string
+	postHook              string
+	ignoreHookFailure     bool
+	signerName            string
+	signerNameSet         bool
+	allowUnknownSigner    bool
+	discoveryTimeout      time.Duration
+	usages                []string
+	usagesSet             bool
+	approveReason         string
+	approveMessage        string
+	approveMsgTemplate    string
+	maxEvents             int
+	requestFrom           string
+	outputMode            string
+	certOut               string
+	referenceFiles        bool
+	noEmbedKey            bool
+	waitAndKeepOpen       bool
+	inheritGroups         bool
+	renewBefore           time.Duration
+	renewBeforeSet        bool
+	clock                 clock.Clock
+	keyRefPath            string
+	clusterName           string
+	deleteGracePeriod     int64
+	validateNamespace     bool
+	auditOut              string
+	auditFormat           string
+	allowedGroups         []string
+	dryRun                string
+	creator               string
+	printConfigPath       bool
+	additionalClusters    []string
+	store                 string
+	keychain              keychain
+	csrLabels             map[string]string
+	outputDir             string
+	filenameTemplate      string
+	inferNamespace        bool
+	skipApproveSigners    []string
+	rawServer             string
+	token                 string
+	caFile                string
+	caChainFile           string
+	caChain               []byte
+	configFile            string
+	allowExistingContext  bool
+	keyFormat             string
+	serialOut             string
+	allowSystemIdentities bool
+	expiration            time.Duration
+	maxExpiration         time.Duration
+	clampExpiration       bool
+	format                string
+	secretName            string
+	secretNamespace       string
+	backend               string
+	issuerName            string
+	issuerKind            string
+	requestNamespace      string
+	dynamicClient         dynamic.Interface
+	stdinKubeconfig       bool
+	mergeInto             string
+	failOnExisting        bool
+	roles                 []string
+	roleGroups            []string
+	allowUnknownRole      bool
+	allowNoGroups         bool
+	outputOwner           string
+	outputGroup           string
+	outputUID             int
+	outputGID             int
+	printSubject          bool
+	summary               string
+	subjectGroups         []string
+	subjectSpecFile       string
+	subjectSpec           *pkix.Name
+	configVersion         string
+	requestProfile        string
+	deleteOnSuccess       bool
+	deleteOnSuccessSet    bool
+	deleteNoticeShown     bool
+	recordOperator        bool
+	description           string
+	deniedRetries         int
+	deniedBackoff         time.Duration
+	printMergedConfig     bool
+	jit                   bool
+	noCurrentContext      bool
+	quiet                 bool
+
+	request        []byte
+	key            []byte
+	outputFileMode os.FileMode
+
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+
+	restConfig *rest.Config
+	start      time.Time
+}
+
+// newCertOptions returns the options of a certificate request holding the
+// defaults of the cert flags, shared by the commands requesting certificates.
+func newCertOptions() *CertOptions {
+	return &CertOptions{
+		configAccess:     clientcmd.NewDefaultPathOptions(),
+		filenameTemplate: defaultFilenameTemplate,
+		recordOperator:   true,
+		configVersion:    clientcmdlatest.Version,
+		outputMode:       defaultOutputMode,
+		store:            storeKubeconfig,
+		renewBefore:      defaultRenewBefore,
+		auditFormat:      auditFormatJSON,
+		dryRun:           dryRunNone,
+		keyFormat:        cmdutilpkix.KeyFormatPKCS8,
+		keyType:          cmdutilpkix.KeyTypeRSA,
+		curve:            cmdutilpkix.CurveP256,
+		deniedBackoff:    time.Second,
+		creator:          defaultCreator(),
+		onConflict:       onConflictError,
+		backend:          backendCSR,
+		issuerKind:       issuerKindIssuer,
+		requestNamespace: DefaultNamespace,
+		format:           formatKubeconfig,
+		signerName:       certificatesv1.KubeAPIServerClientSignerName,
+		usages:           []string{string(certificatesv1.UsageClientAuth)},
+		discoveryTimeout: defaultDiscoveryTimeout,
+		approveReason:    ReasonKconfigCertApprove,
+		approveMessage:   defaultApproveMessage,
+		in:               os.Stdin,
+		out:              os.Stdout,
+		errOut:           os.Stderr,
+	}
+}
+
+func NewCmdCert(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	o := newCertOptions()
+
+	cmd := &cobra.Command{
+		Use:   "cert",
+		Short: "Create kubeconfig file with a specified certificate resources.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(cmdutil.ApplyConfigDefaults(cmd.Flags(), o.configFile, map[string]string{flagCreator: envCreator}))
+			o.deleteOnSuccessSet = cmd.Flags().Changed(flagDeleteOnSuccess)
+			o.renewBeforeSet = cmd.Flags().Changed(flagRenewBefore)
+			o.signerNameSet = cmd.Flags().Changed(flagSignerName)
+			o.usagesSet = cmd.Flags().Changed(flagUsages)
+			o.curveSet = cmd.Flags().Changed(flagCurve)
+			cmdutil.CheckErr(o.Complete(configFlags))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.AddCommand(NewCmdCertAssemble())
+	cmd.AddCommand(NewCmdCertContexts(configFlags))
+	cmd.AddCommand(NewCmdCertList(configFlags))
+	cmd.AddCommand(NewCmdCertApprover(configFlags))
+	cmd.AddCommand(NewCmdCertCredential())
+	cmd.AddCommand(NewCmdCertRefresh(configFlags))
+	cmd.AddCommand(NewCmdCertSigners(configFlags))
+	cmd.AddCommand(NewCmdCertProbe(configFlags))
+
+	cmd.Flags().StringVar(&o.configFile, flagConfigFile, cmdutil.DefaultConfigFile(), "yaml file of flag name to value defaults - explicit flags and environment variables take precedence")
+	cmd.Flags().StringVarP(&o.userName, flagUserName, "u", "", "user name")
+	cmd.MarkFlagRequired(flagUserName)
+	cmd.Flags().StringArrayVarP(&o.groups, flagGroups, "g", nil, "group name")
+	cmd.Flags().StringArrayVar(&o.subjectGroups, flagSubjectGroup, nil, "organization added to the certificate subject only, not to the csr spec groups - --"+flagGroups+" goes to both, for signers authorizing by the subject organizations")
+	cmd.Flags().StringVar(&o.subjectSpecFile, flagSubjectSpec, "", "json or yaml file with the full certificate subject - commonName, organization, organizationalUnit, country, locality, province and serialNumber - used verbatim, the csr spec keeps the user and groups")
+	cmd.Flags().StringArrayVar(&o.roles, flagRole, nil, "role expanding to the groups mapped by --"+flagRoleGroup+", in addition to --"+flagGroups)
+	cmd.Flags().StringArrayVar(&o.roleGroups, flagRoleGroup, nil, "role=group mapping for --"+flagRole+", usually a list in the config file")
+	cmd.Flags().BoolVar(&o.allowUnknownRole, flagAllowUnknownRole, false, "ignore --"+flagRole+" values without --"+flagRoleGroup+" mapping")
+	cmd.Flags().BoolVar(&o.allowSystemIdentities, flagAllowSystemIdentity, false, "allow 'system:' prefixed users and groups such as system:masters, reserved for kubernetes components and granting powerful access")
+	cmd.Flags().StringSliceVar(&o.allowedGroups, flagAllowedGroups, nil, "groups that may be requested with --group - default any")
+	cmd.Flags().StringVarP(&o.output, flagOutput, "o", "", "output file - default stdout")
+	cmd.Flags().BoolVar(&o.printConfigPath, flagPrintConfigPath, false, "print the absolute path the kubeconfig would be written to, or <stdout>, and exit")
+	cmd.Flags().StringVar(&o.outputDir, flagOutputDir, "", "directory to write the kubeconfig to, named by --filename-template")
+	cmd.Flags().StringVar(&o.filenameTemplate, flagFilenameTemplate, o.filenameTemplate, "go template for the kubeconfig file name in --output-dir with .User and .Groups")
+	cmd.Flags().BoolVar(&o.printSubject, flagPrintSubject, false, "print the subject of the csr to stderr before submitting it")
+	cmd.Flags().StringVar(&o.description, flagDescription, "", "note on why the context exists, recorded with its creation time in the "+extensionContext+" context extension")
+	cmd.Flags().BoolVar(&o.recordOperator, flagRecordOperator, o.recordOperator, "record the identity kconfig authenticates as in the "+annotationIssuedBy+" csr annotation, omitted if unknown")
+	cmd.Flags().StringVar(&o.requestProfile, flagRequestProfile, "", "signer profile recorded in the "+annotationRequestProfile+" csr annotation, hinting serial allocation, key usages and validity to signers supporting profiles")
+	cmd.Flags().StringVar(&o.configVersion, flagConfigAPIVersion, o.configVersion, "apiVersion of the written kubeconfig - one of "+strings.Join(clientcmdlatest.Versions, ", "))
+	cmd.Flags().StringVar(&o.summary, flagSummary, "", "print a summary of the issued certificate to stdout - 'json', requires the kubeconfig written to a file")
+	cmd.Flags().StringVar(&o.outputOwner, flagOutputOwner, "", "user name or uid to chown the written files to")
+	cmd.Flags().StringVar(&o.outputGroup, flagOutputGroup, "", "group name or gid to chown the written files to")
+	cmd.Flags().StringVar(&o.outputMode, flagOutputMode, o.outputMode, "octal file mode of the --output file")
+	cmd.Flags().StringVar(&o.certOut, flagCertOut, "", "issued client certificate output file")
+	cmd.Flags().StringVar(&o.clusterName, flagClusterName, "", "name of the emitted cluster - default the name in the source kubeconfig")
+	cmd.Flags().StringArrayVar(&o.additionalClusters, flagAdditionalCluster, nil, "cluster of the current kubeconfig to also emit a context for with the same user, repeatable")
+	cmd.Flags().StringVar(&o.store, flagStore, o.store, "where to keep the private key - 'kubeconfig' embeds it, 'keychain' stores it in the os keychain read back by an exec credential plugin")
+	cmd.Flags().BoolVar(&o.referenceFiles, flagReferenceFiles, false, "reference the --key-out and --cert-out files from the kubeconfig instead of embedding their data")
+	cmd.Flags().BoolVar(&o.inheritGroups, flagInheritGroups, false, "also request the groups of the current user's bearer or oidc token, read with a SelfSubjectReview - client certificate and exec plugin users get the explicit groups only")
+	cmd.Flags().BoolVar(&o.waitAndKeepOpen, flagWaitAndKeepOpen, false, "keep running as a renewal sidecar, re-issuing the certificate and rewriting the kubeconfig --"+flagRenewBefore+" its expiry until SIGINT or SIGTERM")
+	cmd.Flags().DurationVar(&o.renewBefore, flagRenewBefore, o.renewBefore, "with --"+flagWaitAndKeepOpen+" or --"+flagAllowExistingCtx+", renew certificates expiring within this duration, or after two thirds of the validity of shorter lived ones")
+	cmd.Flags().BoolVar(&o.noEmbedKey, flagNoEmbedKey, false, "reference the private key at --"+flagKeyRefPath+" instead of embedding it, for keys provisioned separately - the certificate stays embedded")
+	cmd.Flags().StringVar(&o.keyRefPath, flagKeyRefPath, "", "path of the private key on the machines using the kubeconfig, written as is for --"+flagNoEmbedKey)
+	cmd.Flags().StringVar(&o.serialOut, flagSerialOut, "", "file to write the hex serial number of the issued certificate to")
+	cmd.Flags().StringVar(&o.auditOut, flagAuditOut, "", "json file recording the issued certificate, its csr and the operator")
+	cmd.Flags().StringVar(&o.auditFormat, flagAuditFormat, o.auditFormat, "format of the audit record - 'json' or 'text' for a block to paste into a change ticket, written to --"+flagAuditOut+" or else stderr")
+	cmd.Flags().StringVar(&o.dryRun, flagDryRun, o.dryRun, "one of 'none', 'client' or 'server' - client only prints the csr that would be created, server submits the csr and its approval with apiserver dry-run")
+	cmd.Flags().Lookup(flagDryRun).NoOptDefVal = dryRunClient
+	cmd.Flags().BoolVar(&o.offline, flagOffline, false, "only generate the private key and csr for manual signing, without contacting the cluster")
+	cmd.Flags().BoolVar(&o.requestOnly, flagRequestOnly, false, "only print the base64 encoded csr request as used in the csr spec, without contacting the cluster")
+	cmd.Flags().StringVar(&o.keyOut, flagKeyOut, "", "private key output file - required with --offline and --request-only")
+	cmd.Flags().StringVar(&o.keyFormat, flagKeyFormat, o.keyFormat, "PEM format of the --key-out file - one of 'pkcs8', 'pkcs1' for rsa or 'sec1' for ecdsa keys")
+	cmd.Flags().StringVar(&o.requestFrom, flagRequestFrom, "", "PEM csr file to submit instead of generating a key, '-' reads stdin - the kubeconfig carries no key unless --key-file is given")
+	cmd.Flags().StringVar(&o.keyType, flagKeyType, o.keyType, "private key type - one of 'rsa' or 'ecdsa'")
+	cmd.Flags().StringVar(&o.curve, flagCurve, o.curve, "named curve for ecdsa keys - one of 'P-256', 'P-384' or 'P-521'")
+	cmd.Flags().BoolVar(&o.inCluster, flagInCluster, false, "use the pod's service account to connect to the cluster and as the emitted cluster's server and certificate authority")
+	cmd.Flags().StringVar(&o.rawServer, flagRawServer, "", "https url of the kube-apiserver to use without any kubeconfig, also the emitted cluster's server")
+	cmd.Flags().StringVar(&o.token, flagToken, "", "bearer token for --raw-server")
+	cmd.Flags().StringVar(&o.caFile, flagCAFile, "", "certificate authority file for --raw-server, also the emitted cluster's certificate authority")
+	cmd.Flags().StringVar(&o.caChainFile, flagCAChainFile, "", "pem file of intermediate certificates appended after the issued certificate, for signers returning the leaf only")
+	cmd.Flags().BoolVar(&o.forceRecreateOnDenied, flagForceRecreateOnDenied, false, "delete and recreate the csr once if it gets denied")
+	cmd.Flags().IntVar(&o.deniedRetries, flagDeniedRetries, 0, "delete and recreate a denied csr up to this many times, for approval policies that are briefly unavailable")
+	cmd.Flags().DurationVar(&o.deniedBackoff, flagDeniedBackoff, o.deniedBackoff, "wait before the first --"+flagDeniedRetries+" retry, doubled for each further one and bounded by --"+flagTimeout)
+	cmd.Flags().BoolVar(&o.noCreatorAnnotation, flagNoCreatorAnnotation, false, "omit the creator annotation and label on the csr for clusters rejecting unknown annotations - kconfig can no longer tell such csrs apart")
+	addCreatorFlag(cmd, &o.creator)
+	cmd.Flags().StringToStringVar(&o.csrLabels, flagCSRLabel, nil, "key=value label to set on the csr, repeatable")
+	cmd.Flags().BoolVar(&o.confirmApprove, flagConfirmApprove, false, "print the created csr and ask for confirmation before approving it")
+	cmd.Flags().BoolVarP(&o.yes, flagYes, "y", false, "answer yes to the --confirm-approve prompt")
+	cmd.Flags().BoolVar(&o.allowExistingContext, flagAllowExistingCtx, false, "do nothing when the destination kubeconfig already has a context for the user and cluster with a certificate of the same subject not due for renewal by --"+flagRenewBefore)
+	cmd.Flags().BoolVar(&o.merge, flagMerge, false, "merge the new cluster, user and context into the current kubeconfig file instead of writing a standalone one")
+	cmd.Flags().BoolVar(&o.noCurrentContext, flagNoCurrentContext, false, "leave current-context empty in the written kubeconfig, for fragments merged by tools that choose the context themselves")
+	cmd.Flags().BoolVar(&o.printMergedConfig, flagPrintMerged, false, "also print the merged kubeconfig to stdout with private keys, tokens and passwords redacted - requires --"+flagMerge)
+	cmd.Flags().StringVar(&o.mergeInto, flagMergeInto, "", "kubeconfig file --"+flagMerge+" writes to, created if missing - default the current kubeconfig file")
+	cmd.Flags().StringVar(&o.onConflict, flagOnConflict, o.onConflict, "what to do with existing names on --merge - one of 'error', 'skip' or 'overwrite'")
+	cmd.Flags().BoolVar(&o.verifyLogin, flagVerifyLogin, false, "log in with the new kubeconfig and report the identity the server sees")
+	cmd.Flags().StringVar(&o.csrNameTemplate, flagCSRNameTemplate, "", "go template for the csr name with .User, .Groups and .Hash - default user:group:...")
+	cmd.Flags().StringVar(&o.cnTemplate, flagCNTemplate, "", "go template for the certificate common name with .User and .Groups, e.g. '{{.User}}@example.com' - the kubeconfig keeps the plain user name")
+	cmd.Flags().BoolVar(&o.strictTLS, flagStrictTLS, false, "refuse to talk to the cluster without tls verification")
+	cmd.Flags().StringVar(&o.backend, flagBackend, o.backend, "how to request the certificate - 'csr' or 'certmanager' for a cert-manager CertificateRequest")
+	cmd.Flags().StringVar(&o.issuerName, flagIssuerName, "", "cert-manager issuer signing the --backend=certmanager request")
+	cmd.Flags().StringVar(&o.issuerKind, flagIssuerKind, o.issuerKind, "kind of the cert-manager issuer - 'Issuer' or 'ClusterIssuer'")
+	cmd.Flags().StringVar(&o.requestNamespace, flagRequestNamespace, o.requestNamespace, "namespace of the --backend=certmanager request")
+	cmd.Flags().BoolVar(&o.stdinKubeconfig, flagStdinKubeconfig, false, "read the source kubeconfig from stdin, same as --kubeconfig -")
+	cmd.Flags().StringVar(&o.format, flagFormat, o.format, "output format - 'kubeconfig', 'secret' for a secret manifest carrying the kubeconfig, 'request-json' for the csr spec as json, without contacting the cluster, or 'systemd-creds' for key and certificate files in --output-dir")
+	cmd.Flags().StringVar(&o.secretName, flagSecretName, "", "name of the --format=secret secret")
+	cmd.Flags().StringVar(&o.secretNamespace, flagSecretNamespace, "", "namespace of the --format=secret secret")
+	cmd.Flags().BoolVar(&o.compact, flagCompact, false, "write the kubeconfig base64 encoded on a single line")
+	cmd.Flags().BoolVar(&o.env, flagEnv, false, "prefix the --compact kubeconfig with KUBECONFIG_B64=")
+	cmd.Flags().StringVarP(&o.namespace, flagNamespace, "n", "", "namespace of the emitted context - default '"+DefaultNamespace+"'")
+	cmd.Flags().BoolVar(&o.inferNamespace, flagInferNamespace, false, "without --namespace, use the namespace of a rolebinding of the user or its groups - needs read access to rolebindings")
+	cmd.Flags().BoolVar(&o.validateNamespace, flagValidateNamespace, false, "check that the namespace of the emitted context exists in the cluster")
+	cmd.Flags().StringToStringVar(&o.groupNamespaces, flagGroupNamespace, nil, "group=namespace mapping, the first group of the user with a mapping sets the context namespace over --namespace")
+	cmd.Flags().StringVar(&o.postHook, flagPostHook, "", "shell command run after the kubeconfig is written, a go template with .Kubeconfig, .Username and .Expiry - also in $KCONFIG_KUBECONFIG, $KCONFIG_USERNAME and $KCONFIG_EXPIRY")
+	cmd.Flags().BoolVar(&o.ignoreHookFailure, flagIgnoreHookFailure, false, "do not fail when the --post-hook command fails")
+	cmd.Flags().StringVar(&o.signerName, flagSignerName, o.signerName, "signer name of the csr")
+	cmd.Flags().StringSliceVar(&o.usages, flagUsages, o.usages, "key usages of the csr, must include \"client auth\" and be issued by the --"+flagSignerName+" signer")
+	cmd.Flags().BoolVar(&o.allowUnknownSigner, flagAllowUnknownSigner, false, "skip checking --signer-name against the built-in signers and the signers used in the cluster")
+	addDiscoveryTimeoutFlag(cmd, &o.discoveryTimeout)
+	cmd.Flags().StringSliceVar(&o.skipApproveSigners, flagSkipApproveSigner, nil, "signer names approving csrs on their own, kconfig only waits for the certificate of their csrs")
+	cmd.Flags().StringVar(&o.approveReason, flagApproveReason, o.approveReason, "reason of the csr approval condition")
+	cmd.Flags().StringVar(&o.approveMessage, flagApproveMessage, o.approveMessage, "message of the csr approval condition")
+	cmd.Flags().StringVar(&o.approveMsgTemplate, flagApproveMsgTemplate, "", "go template for the approval message with .Operator, .Time, .Reason, .User and .Groups - overrides --"+flagApproveMessage)
+	cmd.Flags().Int64Var(&o.deleteGracePeriod, flagDeleteGracePeriod, 0, "grace period in seconds for deleting csrs - zero deletes immediately")
+	cmd.Flags().BoolVarP(&o.quiet, flagQuiet, "q", false, "hide the progress indicator shown on a terminal while waiting for the certificate")
+	cmd.Flags().BoolVar(&o.jit, flagJIT, false, "break-glass preset: a "+jitExpiration.String()+" certificate unless --"+flagExpiration+" is set, the csr annotated "+annotationBreakGlass+" and kept for audit unless --"+flagDeleteOnSuccess+" is set")
+	cmd.Flags().DurationVar(&o.expiration, flagExpiration, 0, "requested validity of the certificate, at least 10m - zero leaves it to the signer")
+	cmd.Flags().DurationVar(&o.maxExpiration, flagMaxExpiration, 0, "refuse an --expiration above this duration, also requested without --expiration - zero means no limit")
+	cmd.Flags().BoolVar(&o.clampExpiration, flagClampExpiration, false, "lower an --expiration above --max-expiration to it instead of failing")
+	cmd.Flags().DurationVar(&o.timeout, flagTimeout, 0, "how long to wait for the csr to be issued - zero means wait forever")
+	cmd.Flags().IntVar(&o.maxEvents, flagMaxEvents, 0, "give up after this many csr updates without a certificate - zero means no limit")
+	cmd.Flags().BoolVar(&o.watchExisting, flagWatchExisting, false, "adopt an existing csr for the user and groups instead of creating one")
+	cmd.Flags().BoolVar(&o.failOnExisting, flagFailOnExisting, false, "fail instead of replacing a csr of the same name")
+	addDeleteOnSuccessFlag(cmd, &o.deleteOnSuccess)
+	cmd.Flags().BoolVar(&o.deleteExisting, flagDeleteExisting, false, "delete the adopted csr once the kubeconfig is written - requires --watch-existing")
+	cmd.Flags().StringVar(&o.keyFile, flagKeyFile, "", "private key file matching the adopted or --request-from csr, embedded into the kubeconfig")
+
+	return cmd
+}
+
+func (o *CertOptions) Complete(configFlags *genericclioptions.ConfigFlags) error {
+	o.applyJIT()
+
+	if len(o.roles) != 0 {
+		roleGroups, err := parseRoleGroups(o.roleGroups)
+		if err != nil {
+			return err
+		}
+		o.groups, err = expandRoles(o.groups, o.roles, roleGroups, o.allowUnknownRole)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(o.outputDir) != 0 && len(o.output) != 0 && o.format != formatSystemdCreds {
+		return fmt.Errorf("--%s and --%s are mutually exclusive", flagOutputDir, flagOutput)
+	}
+	// names derived from the groups wait for the groups inherited from the
+	// cluster
+	if o.inheritGroups {
+		if o.offline || o.requestOnly || o.printConfigPath || o.format == formatRequestJSON {
+			return fmt.Errorf("--%s reads the groups from the cluster, it cannot be used with --%s, --%s, --%s or --%s=%s", flagInheritGroups, flagOffline, flagRequestOnly, flagPrintConfigPath, flagFormat, formatRequestJSON)
+		}
+	} else if err := o.completeNames(); err != nil {
+		return err
+	}
+
+	o.clampToMaxExpiration()
+
+	var err error
+	o.outputFileMode, err = cmdutil.ParseFileMode(o.outputMode)
+	if err != nil {
+		return fmt.Errorf("--%s: %v", flagOutputMode, err)
+	}
+	o.outputUID, o.outputGID, err = cmdutil.LookupOwner(o.outputOwner, o.outputGroup)
+	if err != nil {
+		return fmt.Errorf("--%s or --%s: %v", flagOutputOwner, flagOutputGroup, err)
+	}
+
+	if configFlags != nil && configFlags.KubeConfig != nil && *configFlags.KubeConfig == "-" {
+		o.stdinKubeconfig = true
+		*configFlags.KubeConfig = ""
+	}
+	var stdinConfig *clientcmdapi.Config
+	if o.stdinKubeconfig {
+		if o.requestFrom == "-" {
+			return fmt.Errorf("--%s and --%s - cannot both read stdin", flagStdinKubeconfig, flagRequestFrom)
+		}
+		stdinConfig, err = readKubeconfig(o.in)
+		if err != nil {
+			return fmt.Errorf("--%s: %v", flagStdinKubeconfig, err)
+		}
+		o.configAccess = stdinConfigAccess{stdinConfig}
+	} else if configFlags != nil {
+		// read and merge into the kubeconfig of --kubeconfig, if set
+		o.configAccess = configFlags.ToRawKubeConfigLoader().ConfigAccess()
+	}
+
+	if len(o.caChainFile) != 0 {
+		o.caChain, err = os.ReadFile(o.caChainFile)
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.subjectSpecFile) != 0 {
+		o.subjectSpec, err = readSubjectSpec(o.subjectSpecFile)
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.requestFrom) != 0 {
+		if o.requestFrom == "-" {
+			o.request, err = io.ReadAll(o.in)
+		} else {
+			o.request, err = os.ReadFile(o.requestFrom)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.keyFile) != 0 {
+		o.key, err = os.ReadFile(o.keyFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.offline || o.requestOnly || o.printConfigPath || o.format == formatRequestJSON {
+		return nil
+	}
+
+	switch {
+	case len(o.rawServer) != 0:
+		if configFlags != nil && configFlags.KubeConfig != nil && len(*configFlags.KubeConfig) != 0 {
+			return fmt.Errorf("--%s and --kubeconfig are mutually exclusive", flagRawServer)
+		}
+		o.restConfig = &rest.Config{
+			Host:            o.rawServer,
+			BearerToken:     o.token,
+			TLSClientConfig: rest.TLSClientConfig{CAFile: o.caFile},
+		}
+	case o.inCluster:
+		o.restConfig, err = inClusterConfig()
+	case o.stdinKubeconfig:
+		o.restConfig, err = clientcmd.NewDefaultClientConfig(*stdinConfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	default:
+		o.restConfig, err = configFlags.ToRESTConfig()
+	}
+	if err != nil {
+		return err
+	}
+	o.clientSet, err = clientset.NewForConfig(o.restConfig)
+	if err != nil {
+		return err
+	}
+	if o.backend == backendCertManager {
+		o.dynamicClient, err = dynamic.NewForConfig(o.restConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.inheritGroups {
+		err = o.inheritTokenGroups()
+		if err != nil {
+			return err
+		}
+		err = o.completeNames()
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.inferNamespace && len(o.namespace) == 0 {
+		o.namespace = inferNamespace(o.clientSet, o.userName, o.groups)
+	}
+	return nil
+}
+
+// completeNames derives the csr name, the common name and the output file
+// from the user and groups.
+func (o *CertOptions) completeNames() error {
+	o.csrName = o.userName + ":" + strings.Join(o.groups, ":")
+	if len(o.csrNameTemplate) != 0 {
+		name, err := renderCSRName(o.csrNameTemplate, o.userName, o.groups)
+		if err != nil {
+			return err
+		}
+		o.csrName = name
+	}
+	if len(o.cnTemplate) != 0 {
+		cn, err := renderCommonName(o.cnTemplate, o.userName, o.groups)
+		if err != nil {
+			return err
+		}
+		o.commonName = cn
+	}
+
+	if len(o.outputDir) != 0 && o.format != formatSystemdCreds {
+		name, err := renderFilename(o.filenameTemplate, o.userName, o.groups)
+		if err != nil {
+			return err
+		}
+		o.output = filepath.Join(o.outputDir, name)
+	}
+	return nil
+}
+
+func (o *CertOptions) Validate() error {
+	if errs := path.IsValidPathSegmentName(o.csrName); len(errs) != 0 {
+		return fmt.Errorf("invalid csr name %q: %s", o.csrName, strings.Join(errs, ", "))
+	}
+	// groups come from --group, --role and the config file, checked once
+	// all are resolved
+	if len(o.groups) == 0 && !o.allowNoGroups {
+		return fmt.Errorf("no groups left after resolving --%s, --%s and the config file - a certificate without groups only gets the rbac bindings of user %q", flagGroups, flagRole, o.userName)
+	}
+	// the kube-apiserver-client signer makes every subject organization a group
+	if err := validateAllowedGroups(o.organizations(), o.allowedGroups); err != nil {
+		return err
+	}
+	if identities := systemIdentities(o.subjectCommonName(), o.organizations()); len(identities) != 0 {
+		if !o.allowSystemIdentities {
+			return fmt.Errorf("%q are reserved for kubernetes components, use --%s to issue a certificate for them anyway", identities, flagAllowSystemIdentity)
+		}
+		klog.Warningf("issuing a certificate for the reserved identities %q", identities)
+	}
+	if o.strictTLS && o.restConfig != nil && o.restConfig.TLSClientConfig.Insecure {
+		return fmt.Errorf("--%s: the connection to %s skips tls verification, fix insecure-skip-tls-verify in the kubeconfig", flagStrictTLS, o.restConfig.Host)
+	}
+	if o.offline && o.requestOnly {
+		return fmt.Errorf("--%s and --%s are mutually exclusive", flagOffline, flagRequestOnly)
+	}
+	if o.offline || o.requestOnly {
+		mode := flagOffline
+		if o.requestOnly {
+			mode = flagRequestOnly
+		}
+		if len(o.keyOut) == 0 {
+			return fmt.Errorf("--%s requires --%s", mode, flagKeyOut)
+		}
+		if o.watchExisting {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", mode, flagWatchExisting)
+		}
+		if o.inCluster {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", mode, flagInCluster)
+		}
+		if len(o.requestFrom) != 0 {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", mode, flagRequestFrom)
+		}
+		if len(o.certOut) != 0 {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", mode, flagCertOut)
+		}
+		if len(o.auditOut) != 0 {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", mode, flagAuditOut)
+		}
+		if len(o.serialOut) != 0 {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", mode, flagSerialOut)
+		}
+	}
+	switch o.dryRun {
+	case dryRunNone:
+	case dryRunClient, dryRunServer:
+		if o.offline || o.requestOnly || o.watchExisting {
+			return fmt.Errorf("--%s requires a new csr, it cannot be used with --%s, --%s or --%s", flagDryRun, flagOffline, flagRequestOnly, flagWatchExisting)
+		}
+	default:
+		return fmt.Errorf("--%s must be '%s', '%s' or '%s'", flagDryRun, dryRunNone, dryRunClient, dryRunServer)
+	}
+	if len(o.rawServer) != 0 {
+		if err := o.validateRawServer(); err != nil {
+			return err
+		}
+	} else if len(o.token) != 0 || len(o.caFile) != 0 {
+		return fmt.Errorf("--%s and --%s require --%s", flagToken, flagCAFile, flagRawServer)
+	}
+	if len(o.approveMsgTemplate) != 0 {
+		// fail before the csr exists rather than when approving it
+		if _, err := renderApproveMessage(o.approveMsgTemplate, approveMessageData{}); err != nil {
+			return err
+		}
+	}
+	if o.stdinKubeconfig && (o.merge && len(o.mergeInto) == 0 || o.printConfigPath || o.inCluster || len(o.rawServer) != 0) {
+		return fmt.Errorf("--%s has no kubeconfig file, it cannot be used with --%s, --%s, --%s or --%s", flagStdinKubeconfig, flagMerge, flagPrintConfigPath, flagInCluster, flagRawServer)
+	}
+	if strings.ContainsAny(o.clusterName, " \t\n") {
+		return fmt.Errorf("invalid --%s %q: must not contain whitespace", flagClusterName, o.clusterName)
+	}
+	if len(o.additionalClusters) != 0 {
+		if err := o.validateAdditionalClusters(); err != nil {
+			return err
+		}
+	}
+	switch o.store {
+	case storeKubeconfig:
+	case storeKeychain:
+		if o.referenceFiles {
+			return fmt.Errorf("--%s=%s and --%s are mutually exclusive", flagStore, storeKeychain, flagReferenceFiles)
+		}
+		if (o.watchExisting || len(o.requestFrom) != 0) && len(o.keyFile) == 0 {
+			return fmt.Errorf("--%s=%s without a generated key requires --%s", flagStore, storeKeychain, flagKeyFile)
+		}
+		// probe the keychain now, an unusable one after the certificate is
+		// issued would lose the key
+		k, err := newKeychain()
+		if err != nil {
+			return err
+		}
+		o.keychain = k
+	default:
+		return fmt.Errorf("--%s must be '%s' or '%s'", flagStore, storeKubeconfig, storeKeychain)
+	}
+	if o.referenceFiles {
+		if len(o.keyOut) == 0 || len(o.certOut) == 0 {
+			return fmt.Errorf("--%s requires --%s and --%s", flagReferenceFiles, flagKeyOut, flagCertOut)
+		}
+		if (o.watchExisting || len(o.requestFrom) != 0) && len(o.keyFile) == 0 {
+			return fmt.Errorf("--%s without a generated key requires --%s", flagReferenceFiles, flagKeyFile)
+		}
+	}
+	if o.noEmbedKey {
+		if len(o.keyRefPath) == 0 {
+			return fmt.Errorf("--%s requires --%s", flagNoEmbedKey, flagKeyRefPath)
+		}
+		if o.referenceFiles || o.store == storeKeychain {
+			return fmt.Errorf("--%s cannot be used with --%s or --%s=%s", flagNoEmbedKey, flagReferenceFiles, flagStore, storeKeychain)
+		}
+		if !o.watchExisting && len(o.requestFrom) == 0 && len(o.keyOut) == 0 {
+			return fmt.Errorf("--%s requires --%s to keep the generated key", flagNoEmbedKey, flagKeyOut)
+		}
+		if o.format == formatSystemdCreds {
+			return fmt.Errorf("--%s cannot be used with --%s=%s, which writes the key as a credential", flagNoEmbedKey, flagFormat, formatSystemdCreds)
+		}
+		if o.verifyLogin {
+			return fmt.Errorf("--%s and --%s are mutually exclusive, the kubeconfig holds no key to log in with", flagNoEmbedKey, flagVerifyLogin)
+		}
+	} else if len(o.keyRefPath) != 0 {
+		return fmt.Errorf("--%s requires --%s", flagKeyRefPath, flagNoEmbedKey)
+	}
+	if o.deniedRetries < 0 || o.deniedBackoff < 0 {
+		return fmt.Errorf("--%s and --%s must not be negative", flagDeniedRetries, flagDeniedBackoff)
+	}
+	if o.deniedRetries > 0 && o.forceRecreateOnDenied {
+		return fmt.Errorf("--%s and --%s are mutually exclusive", flagDeniedRetries, flagForceRecreateOnDenied)
+	}
+	if o.watchExisting && o.failOnExisting {
+		return fmt.Errorf("--%s and --%s are mutually exclusive", flagWatchExisting, flagFailOnExisting)
+	}
+	if !o.watchExisting && o.deleteExisting {
+		return fmt.Errorf("--%s requires --%s", flagDeleteExisting, flagWatchExisting)
+	}
+	if len(o.keyFile) != 0 && !o.watchExisting && len(o.requestFrom) == 0 {
+		return fmt.Errorf("--%s requires --%s or --%s", flagKeyFile, flagWatchExisting, flagRequestFrom)
+	}
+	if len(o.requestFrom) != 0 {
+		if o.watchExisting {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", flagRequestFrom, flagWatchExisting)
+		}
+		if err := o.validateRequest(); err != nil {
+			return err
+		}
+	}
+	switch o.keyType {
+	case cmdutilpkix.KeyTypeRSA:
+		if o.curveSet {
+			return fmt.Errorf("--%s requires --%s=%s", flagCurve, flagKeyType, cmdutilpkix.KeyTypeECDSA)
+		}
+	case cmdutilpkix.KeyTypeECDSA:
+		if _, err := cmdutilpkix.ParseCurve(o.curve); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("--%s must be '%s' or '%s'", flagKeyType, cmdutilpkix.KeyTypeRSA, cmdutilpkix.KeyTypeECDSA)
+	}
+	if err := validateCreator(o.creator); err != nil {
+		return err
+	}
+	if err := validateCSRLabels(o.csrLabels); err != nil {
+		return err
+	}
+	switch o.keyFormat {
+	case cmdutilpkix.KeyFormatPKCS8:
+	case cmdutilpkix.KeyFormatPKCS1, cmdutilpkix.KeyFormatSEC1:
+		if len(o.keyOut) == 0 {
+			return fmt.Errorf("--%s requires --%s", flagKeyFormat, flagKeyOut)
+		}
+		// pkcs1 only encodes rsa and sec1 only ecdsa keys
+		if len(o.keyFile) == 0 && (o.keyFormat == cmdutilpkix.KeyFormatPKCS1) != (o.keyType == cmdutilpkix.KeyTypeRSA) {
+			return fmt.Errorf("--%s=%s does not support --%s=%s keys", flagKeyFormat, o.keyFormat, flagKeyType, o.keyType)
+		}
+	default:
+		return fmt.Errorf("--%s must be '%s', '%s' or '%s'", flagKeyFormat, cmdutilpkix.KeyFormatPKCS8, cmdutilpkix.KeyFormatPKCS1, cmdutilpkix.KeyFormatSEC1)
+	}
+	if o.yes && !o.confirmApprove {
+		return fmt.Errorf("--%s requires --%s", flagYes, flagConfirmApprove)
+	}
+	if o.verifyLogin && (o.watchExisting || len(o.requestFrom) != 0) && len(o.keyFile) == 0 {
+		return fmt.Errorf("--%s without a generated key requires --%s", flagVerifyLogin, flagKeyFile)
+	}
+	if len(o.namespace) != 0 {
+		if errs := validation.IsDNS1123Label(o.namespace); len(errs) != 0 {
+			return fmt.Errorf("invalid --%s %q: %s", flagNamespace, o.namespace, strings.Join(errs, ", "))
+		}
+	}
+	for group, namespace := range o.groupNamespaces {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) != 0 {
+			return fmt.Errorf("invalid --%s namespace %q for group %q: %s", flagGroupNamespace, namespace, group, strings.Join(errs, ", "))
+		}
+	}
+	if len(o.postHook) != 0 {
+		if _, err := parsePostHook(o.postHook); err != nil {
+			return err
+		}
+	}
+	if err := o.validateBackend(); err != nil {
+		return err
+	}
+	if err := o.validateFormat(); err != nil {
+		return err
+	}
+	if err := o.validateSummary(); err != nil {
+		return err
+	}
+	if err := o.validateConfigAPIVersion(); err != nil {
+		return err
+	}
+	if o.noCurrentContext && (o.merge || o.format == formatSystemdCreds) {
+		return fmt.Errorf("--%s only applies to a standalone kubeconfig, --%s keeps the current context and --%s=%s has none", flagNoCurrentContext, flagMerge, flagFormat, formatSystemdCreds)
+	}
+	if o.printMergedConfig && !o.merge {
+		return fmt.Errorf("--%s requires --%s", flagPrintMerged, flagMerge)
+	}
+	if o.printMergedConfig && len(o.summary) != 0 {
+		return fmt.Errorf("--%s and --%s both print to stdout", flagPrintMerged, flagSummary)
+	}
+	if len(o.requestProfile) != 0 {
+		if errs := validation.IsDNS1123Subdomain(o.requestProfile); len(errs) != 0 {
+			return fmt.Errorf("invalid --%s %q: %s", flagRequestProfile, o.requestProfile, strings.Join(errs, ", "))
+		}
+	}
+	if o.env && !o.compact {
+		return fmt.Errorf("--%s requires --%s", flagEnv, flagCompact)
+	}
+	if o.compact && o.merge {
+		return fmt.Errorf("--%s and --%s are mutually exclusive", flagCompact, flagMerge)
+	}
+	if len(o.outputDir) != 0 {
+		if fi, err := os.Stat(o.outputDir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("--%s %q is not a directory", flagOutputDir, o.outputDir)
+		}
+	}
+	if o.merge && len(o.output) != 0 {
+		return fmt.Errorf("--%s and --%s are mutually exclusive", flagMerge, flagOutput)
+	}
+	if len(o.mergeInto) != 0 {
+		if !o.merge {
+			return fmt.Errorf("--%s requires --%s", flagMergeInto, flagMerge)
+		}
+		if fi, err := os.Stat(o.mergeInto); err == nil && fi.IsDir() {
+			return fmt.Errorf("--%s %q is a directory", flagMergeInto, o.mergeInto)
+		}
+		if err := cmdutil.CheckWritable(o.mergeInto); err != nil {
+			return fmt.Errorf("--%s: %v", flagMergeInto, err)
+		}
+	}
+	if err := validateOnConflict(o.onConflict); err != nil {
+		return err
+	}
+	if len(o.outputOwner) != 0 || len(o.outputGroup) != 0 {
+		if err := cmdutil.CheckChown(o.outputUID, o.outputGID); err != nil {
+			return fmt.Errorf("--%s or --%s: %v", flagOutputOwner, flagOutputGroup, err)
+		}
+	}
+	if len(o.auditOut) != 0 {
+		if err := cmdutil.CheckWritable(o.auditOut); err != nil {
+			return fmt.Errorf("--%s: %v", flagAuditOut, err)
+		}
+	}
+	if err := validateAuditFormat(o.auditFormat); err != nil {
+		return err
+	}
+	if len(o.caChain) != 0 {
+		if err := validateCAChain(o.caChain); err != nil {
+			return err
+		}
+	}
+	if o.subjectSpec != nil {
+		if err := o.validateSubjectSpec(); err != nil {
+			return err
+		}
+	}
+	if o.waitAndKeepOpen {
+		if err := o.validateKeepOpen(); err != nil {
+			return err
+		}
+	} else if o.renewBeforeSet && !o.allowExistingContext {
+		return fmt.Errorf("--%s requires --%s or --%s", flagRenewBefore, flagWaitAndKeepOpen, flagAllowExistingCtx)
+	}
+	if o.validateNamespace && o.clientSet != nil {
+		if err := o.checkNamespace(); err != nil {
+			return err
+		}
+	}
+	if err := validateSignerUsages(o.signerName, o.usages); err != nil {
+		return err
+	}
+	if o.discoveryTimeout < 0 {
+		return fmt.Errorf("--%s must not be negative", flagDiscoveryTimeout)
+	}
+	if !o.allowUnknownSigner && o.clientSet != nil {
+		if err := validateSigner(o.clientSet, o.signerName, o.discoveryTimeout); err != nil {
+			return err
+		}
+	}
+	if o.expiration != 0 && (o.expiration < minExpiration || o.expiration.Seconds() > math.MaxInt32) {
+		return fmt.Errorf("--%s must be at least %s and at most %d seconds", flagExpiration, minExpiration, math.MaxInt32)
+	}
+	if o.maxExpiration < 0 {
+		return fmt.Errorf("--%s must not be negative", flagMaxExpiration)
+	}
+	if o.maxExpiration > 0 && o.expiration > o.maxExpiration {
+		return fmt.Errorf("--%s %s exceeds --%s %s, use --%s to lower it", flagExpiration, o.expiration, flagMaxExpiration, o.maxExpiration, flagClampExpiration)
+	}
+	if o.timeout < 0 {
+		return fmt.Errorf("--%s must not be negative", flagTimeout)
+	}
+	if o.deleteGracePeriod < 0 {
+		return fmt.Errorf("--%s must not be negative", flagDeleteGracePeriod)
+	}
+	if o.maxEvents < 0 {
+		return fmt.Errorf("--%s must not be negative", flagMaxEvents)
+	}
+	if !o.printConfigPath && !o.offline && !o.requestOnly && o.format != formatRequestJSON {
+		if err := o.validateSourceCluster(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateSourceCluster checks the cluster written to the kubeconfig, e.g.
+// for a missing server, before a certificate is requested for it.
+// writeKubeconfig validates the whole kubeconfig again.
+func (o *CertOptions) validateSourceCluster() error {
+	name, cluster, err := o.sourceCluster()
+	if err != nil {
+		return err
+	}
+	config := clientcmdapi.NewConfig()
+	config.Clusters[name] = cluster
+	if err := clientcmd.Validate(*config); err != nil {
+		return fmt.Errorf("invalid kubeconfig: %v", err)
+	}
+	return nil
+}
+
+// defaultCreator returns $KCONFIG_CREATOR, falling back to kconfig.local.io.
+func defaultCreator() string {
+	if creator := os.Getenv(envCreator); len(creator) != 0 {
+		return creator
+	}
+	return creatorKconfig
+}
+
+// creatorSelector selects the csrs labeled with the creator and, for a
+// custom creator, those of the default creator as well.
+func creatorSelector(creator string) string {
+	if creator == creatorKconfig {
+		return labelCreator + "=" + creator
+	}
+	return fmt.Sprintf("%s in (%s,%s)", labelCreator, creator, creatorKconfig)
+}
+
+// isCreator reports whether the creator annotation value is the creator or
+// the default creator.
+func isCreator(value string, creator string) bool {
+	return value == creator || value == creatorKconfig
+}
+
+func addCreatorFlag(cmd *cobra.Command, creator *string) {
+	cmd.Flags().StringVar(creator, flagCreator, defaultCreator(), "creator annotation and label value marking the csrs of this kconfig - default $"+envCreator+" or "+creatorKconfig+", whose csrs list and approver always match")
+}
+
+func validateCreator(creator string) error {
+	if errs := validation.IsValidLabelValue(creator); len(creator) == 0 || len(errs) != 0 {
+		return fmt.Errorf("invalid --%s %q: must be a non-empty label value %s", flagCreator, creator, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (o *CertOptions) validateFormat() error {
+	if o.format != formatSecret && (len(o.secretName) != 0 || len(o.secretNamespace) != 0) {
+		return fmt.Errorf("--%s and --%s require --%s=%s", flagSecretName, flagSecretNamespace, flagFormat, formatSecret)
+	}
+
+	switch o.format {
+	case formatKubeconfig:
+		return nil
+	case formatSecret:
+		return o.validateFormatSecret()
+	case formatRequestJSON:
+		return o.validateFormatRequestJSON()
+	case formatSystemdCreds:
+		return o.validateFormatSystemdCreds()
+	}
+	return fmt.Errorf("--%s must be '%s', '%s', '%s' or '%s'", flagFormat, formatKubeconfig, formatSecret, formatRequestJSON, formatSystemdCreds)
+}
+
+func (o *CertOptions) validateFormatSecret() error {
+	if o.merge || o.compact {
+		return fmt.Errorf("--%s=%s cannot be used with --%s or --%s", flagFormat, o.format, flagMerge, flagCompact)
+	}
+	if len(o.secretName) == 0 {
+		return fmt.Errorf("--%s=%s requires --%s", flagFormat, formatSecret, flagSecretName)
+	}
+	if errs := validation.IsDNS1123Subdomain(o.secretName); len(errs) != 0 {
+		return fmt.Errorf("invalid --%s %q: %s", flagSecretName, o.secretName, strings.Join(errs, ", "))
+	}
+	if len(o.secretNamespace) != 0 {
+		if errs := validation.IsDNS1123Label(o.secretNamespace); len(errs) != 0 {
+			return fmt.Errorf("invalid --%s %q: %s", flagSecretNamespace, o.secretNamespace, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+func (o *CertOptions) validateFormatRequestJSON() error {
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{o.merge, flagMerge},
+		{o.compact, flagCompact},
+		{o.offline, flagOffline},
+		{o.requestOnly, flagRequestOnly},
+		{o.watchExisting, flagWatchExisting},
+		{o.dryRun != dryRunNone, flagDryRun},
+		{o.backend != backendCSR, flagBackend},
+		{len(o.certOut) != 0, flagCertOut},
+		{len(o.auditOut) != 0, flagAuditOut},
+		{len(o.serialOut) != 0, flagSerialOut},
+	} {
+		if f.set {
+			return fmt.Errorf("--%s=%s cannot be used with --%s", flagFormat, o.format, f.name)
+		}
+	}
+	if len(o.requestFrom) == 0 && len(o.keyOut) == 0 {
+		return fmt.Errorf("--%s=%s requires --%s for the generated key", flagFormat, o.format, flagKeyOut)
+	}
+	return nil
+}
+
+func (o *CertOptions) validateRawServer() error {
+	u, err := url.Parse(o.rawServer)
+	if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+		return fmt.Errorf("invalid --%s %q: must be an https url", flagRawServer, o.rawServer)
+	}
+	if len(o.token) == 0 {
+		return fmt.Errorf("--%s requires --%s", flagRawServer, flagToken)
+	}
+	for _, conflict := range []struct {
+		set  bool
+		flag string
+	}{
+		{o.inCluster, flagInCluster},
+		{o.merge, flagMerge},
+		{len(o.additionalClusters) != 0, flagAdditionalCluster},
+	} {
+		if conflict.set {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", flagRawServer, conflict.flag)
+		}
+	}
+	return nil
+}
+
+func (o *CertOptions) validateAdditionalClusters() error {
+	startingConfig, err := loadStartingConfig(o.configAccess)
+	if err != nil {
+		return err
+	}
+	for _, name := range o.additionalClusters {
+		if _, ok := startingConfig.Clusters[name]; !ok {
+			return fmt.Errorf("--%s %q not found in kubeconfig %s", flagAdditionalCluster, name, strings.Join(o.configAccess.GetLoadingPrecedence(), string(filepath.ListSeparator)))
+		}
+	}
+	return nil
+}
+
+func validateCSRLabels(csrLabels map[string]string) error {
+	for key, value := range csrLabels {
+		if key == labelCreator || key == labelCreatedBy {
+			return fmt.Errorf("invalid --%s %q: the label is set by kconfig", flagCSRLabel, key)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+			return fmt.Errorf("invalid --%s key %q: %s", flagCSRLabel, key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+			return fmt.Errorf("invalid --%s value %q for %q: %s", flagCSRLabel, value, key, strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// systemIdentities returns the user and groups carrying the "system:" prefix
+// kubernetes reserves for its own components.
+func systemIdentities(userName string, groups []string) []string {
+	var identities []string
+	for _, identity := range append([]string{userName}, groups...) {
+		if strings.HasPrefix(identity, systemIdentityPrefix) {
+			identities = append(identities, identity)
+		}
+	}
+	return identities
+}
+
+// validateAllowedGroups fails for groups outside a non-empty allowlist.
+func validateAllowedGroups(groups []string, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	for _, group := range groups {
+		if !contains(allowed, group) {
+			return fmt.Errorf("group %q is not in --%s %q", group, flagAllowedGroups, allowed)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkNamespace verifies that the namespace of the emitted context exists,
+// only warning when the issuing user may not read namespaces.
+func (o *CertOptions) checkNamespace() error {
+	namespace := o.contextNamespace()
+	_, err := o.clientSet.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		return fmt.Errorf("namespace %q of the emitted context does not exist", namespace)
+	case apierrors.IsForbidden(err):
+		klog.Warningf("unable to verify namespace %q: %v", namespace, err)
+		return nil
+	}
+	return err
+}
+
+// validateRequest checks that the csr read by --request-from parses, is
+// self-signed correctly and matches the --key-file if one is given.
+func (o *CertOptions) validateRequest() error {
+	csr, err := cmdutilpkix.ParseCertificateRequestPem(o.request)
+	if err != nil {
+		return fmt.Errorf("--%s: %v", flagRequestFrom, err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("--%s: invalid csr signature: %v", flagRequestFrom, err)
+	}
+
+	if len(o.key) == 0 {
+		return nil
+	}
+
+	key, err := cmdutilpkix.ParsePrivateKeyPem(o.key)
+	if err != nil {
+		return fmt.Errorf("--%s: %v", flagKeyFile, err)
+	}
+	if !cmdutilpkix.PublicKeyEqual(key.Public(), csr.PublicKey) {
+		return fmt.Errorf("--%s does not match the public key of the --%s csr", flagKeyFile, flagRequestFrom)
+	}
+
+	return nil
+}
+
+func (o *CertOptions) Run() error {
+	o.start = time.Now()
+
+	if o.printConfigPath {
+		path, err := o.configPath()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(o.out, path)
+		return nil
+	}
+
+	if o.printSubject {
+		subject, err := o.subject()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(o.errOut, "Subject: %s\n", subject)
+	}
+
+	if o.format == formatRequestJSON {
+		return o.runRequestJSON()
+	}
+
+	if o.offline || o.requestOnly {
+		return o.runOffline()
+	}
+
+	if o.watchExisting {
+		return o.runWatchExisting()
+	}
+
+	if o.dryRun != dryRunNone {
+		return o.runDryRun()
+	}
+
+	if o.allowExistingContext {
+		name, ok, err := o.existingContext()
+		if err != nil {
+			return err
+		}
+		if ok {
+			fmt.Fprintf(o.errOut, "Context %q already has a valid certificate, nothing to do.\n", name)
+			return nil
+		}
+	}
+
+	if o.waitAndKeepOpen {
+		return o.runKeepOpen()
+	}
+
+	_, err := o.issueAndWrite()
+	return err
+}
+
+// issueAndWrite issues the certificate and writes the kubeconfig, returning
+// the certificate.
+func (o *CertOptions) issueAndWrite() ([]byte, error) {
+	issuer := o.issuer()
+	defer deleteOnPanic(issuer)
+	key, cert, err := issuer.issue()
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.writeKubeconfig(key, cert)
+	if err != nil {
+		return nil, err
+	}
+	if o.jit {
+		o.printJITExpiry(cert)
+	}
+
+	return cert, o.deleteIssued(issuer)
+}
+
+// existingError describes a signing request found by --fail-on-existing.
+func existingError(kind string, existing metav1.Object) error {
+	creator := existing.GetAnnotations()[annotationCreator]
+	if len(creator) == 0 {
+		creator = "unknown"
+	}
+	return fmt.Errorf("%s %q already exists, created %s by creator %s - refusing to replace it with --%s", kind, existing.GetName(), existing.GetCreationTimestamp().UTC().Format(time.RFC3339), creator, flagFailOnExisting)
+}
+
+// issue replaces any existing csr of the same name by a new one and waits
+// for its certificate, returning the private key matching the csr if known.
+func (o *CertOptions) issue() ([]byte, []byte, error) {
+	existing, err := o.getCertificateSigningRequest()
+	if err == nil && o.failOnExisting {
+		return nil, nil, existingError("csr", existing)
+	}
+	if err == nil {
+		klog.V(2).InfoS("delete existing csr", "csr", o.csrName, "phase", "cleanup", "duration", time.Since(o.start))
+		err := o.deleteCertificatesV1CertificateSigningRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	key, request := o.key, o.request
+	if len(o.requestFrom) == 0 {
+		key, request, err = o.createCertificateRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	csr, err := o.issueCertificate(request)
+	retries, backoff := o.deniedRetries, o.deniedBackoff
+	if o.forceRecreateOnDenied {
+		retries, backoff = 1, 0
+	}
+	for retry := 0; retry < retries && errors.Is(err, errCertificateDenied); retry++ {
+		if o.timeout > 0 && time.Since(o.start)+backoff > o.timeout {
+			klog.V(2).InfoS("no time left to retry denied csr", "csr", o.csrName, "phase", "recreate", "duration", time.Since(o.start), "backoff", backoff)
+			break
+		}
+		klog.V(2).InfoS("recreate denied csr", "csr", o.csrName, "phase", "recreate", "duration", time.Since(o.start), "retry", retry+1, "backoff", backoff, "err", err)
+		time.Sleep(backoff)
+		backoff *= 2
+
+		err = o.deleteCertificatesV1CertificateSigningRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+		csr, err = o.issueCertificate(request)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, csr.Status.Certificate, nil
+}
+
+type csrNameData struct {
+	User   string
+	Groups []string
+	Hash   string
+}
+
+func renderCSRName(text string, userName string, groups []string) (string, error) {
+	tmpl, err := template.New(flagCSRNameTemplate).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("--%s: %v", flagCSRNameTemplate, err)
+	}
+
+	sum := sha256.Sum256([]byte(userName + ":" + strings.Join(groups, ":")))
+	var name strings.Builder
+	err = tmpl.Execute(&name, csrNameData{
+		User:   userName,
+		Groups: groups,
+		Hash:   hex.EncodeToString(sum[:])[:8],
+	})
+	if err != nil {
+		return "", fmt.Errorf("--%s: %v", flagCSRNameTemplate, err)
+	}
+
+	return name.String(), nil
+}
+
+type approveMessageData struct {
+	Operator string
+	Time     time.Time
+	Reason   string
+	User     string
+	Groups   []string
+}
+
+func renderApproveMessage(text string, data approveMessageData) (string, error) {
+	tmpl, err := template.New(flagApproveMsgTemplate).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("--%s: %v", flagApproveMsgTemplate, err)
+	}
+
+	var message strings.Builder
+	err = tmpl.Execute(&message, data)
+	if err != nil {
+		return "", fmt.Errorf("--%s: %v", flagApproveMsgTemplate, err)
+	}
+
+	return message.String(), nil
+}
+
+type commonNameData struct {
+	User   string
+	Groups []string
+}
+
+// renderCommonName renders the --cn-template, refusing common names that are
+// empty, longer than the 64 characters x509 allows or hold control
+// characters.
+func renderCommonName(text string, userName string, groups []string) (string, error) {
+	tmpl, err := template.New(flagCNTemplate).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("--%s: %v", flagCNTemplate, err)
+	}
+
+	var cn strings.Builder
+	err = tmpl.Execute(&cn, commonNameData{User: userName, Groups: groups})
+	if err != nil {
+		return "", fmt.Errorf("--%s: %v", flagCNTemplate, err)
+	}
+
+	s := cn.String()
+	switch {
+	case len(strings.TrimSpace(s)) == 0:
+		return "", fmt.Errorf("--%s: empty common name", flagCNTemplate)
+	case utf8.RuneCountInString(s) > maxCommonNameLength:
+		return "", fmt.Errorf("--%s: common name %q is longer than %d characters", flagCNTemplate, s, maxCommonNameLength)
+	case strings.IndexFunc(s, unicode.IsControl) >= 0:
+		return "", fmt.Errorf("--%s: common name %q has control characters", flagCNTemplate, s)
+	}
+	return s, nil
+}
+
+type filenameData struct {
+	User   string
+	Groups []string
+}
+
+// renderFilename renders the --filename-template, refusing names that would
+// leave --output-dir.
+func renderFilename(text string, userName string, groups []string) (string, error) {
+	tmpl, err := template.New(flagFilenameTemplate).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("--%s: %v", flagFilenameTemplate, err)
+	}
+
+	var name strings.Builder
+	err = tmpl.Execute(&name, filenameData{User: userName, Groups: groups})
+	if err != nil {
+		return "", fmt.Errorf("--%s: %v", flagFilenameTemplate, err)
+	}
+
+	switch s := name.String(); {
+	case len(s) == 0, s == ".", s == "..", strings.ContainsAny(s, "/\\\x00"):
+		return "", fmt.Errorf("--%s: %q is not a file name", flagFilenameTemplate, s)
+	default:
+		return s, nil
+	}
+}
+
+func (o *CertOptions) issueCertificate(request []byte) (*certificatesv1.CertificateSigningRequest, error) {
+	klog.V(2).InfoS("create csr", "csr", o.csrName, "phase", "create", "duration", time.Since(o.start))
+	csr, err := o.createCertificatesV1CertificateSigningRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if contains(o.skipApproveSigners, o.signerName) {
+		klog.V(2).InfoS("skip approval of csr", "csr", o.csrName, "signer", o.signerName, "phase", "approve", "duration", time.Since(o.start))
+		return o.waitForCertificate()
+	}
+
+	if o.confirmApprove {
+		err = o.confirmApproval(csr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = o.approveCertificate(csr)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.waitForCertificate()
+}
+
+func (o *CertOptions) approveCertificate(csr *certificatesv1.CertificateSigningRequest) error {
+	message := o.approveMessage
+	if len(o.approveMsgTemplate) != 0 {
+		var err error
+		message, err = renderApproveMessage(o.approveMsgTemplate, approveMessageData{
+			Operator: o.operator(),
+			Time:     time.Now().UTC(),
+			Reason:   o.approveReason,
+			User:     o.userName,
+			Groups:   o.groups,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
+		approvalCondition(o.approveReason, message),
+	}
+
+	klog.V(2).InfoS("approve csr", "csr", o.csrName, "phase", "approve", "duration", time.Since(o.start))
+	_, err := o.clientSet.CertificatesV1().
+		CertificateSigningRequests().
+		UpdateApproval(context.TODO(), o.csrName, csr, metav1.UpdateOptions{
+			DryRun: o.dryRunOption(),
+		})
+	return err
+}
+
+// runDryRun reports the csr that would be created and approved, with
+// --dry-run=server after the apiserver admitted it without persisting it.
+func (o *CertOptions) runDryRun() error {
+	request := o.request
+	if len(o.requestFrom) == 0 {
+		var err error
+		_, request, err = o.createCertificateRequest()
+		if err != nil {
+			return err
+		}
+	}
+
+	approvalChecked := true
+	if o.dryRun == dryRunServer {
+		var err error
+		approvalChecked, err = o.serverDryRun(request)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !approvalChecked {
+		fmt.Fprintf(o.errOut, "csr %q for user %q and groups %q would be created with signer %q, its approval was not checked (%s dry run)\n", o.csrName, o.userName, o.groups, o.signerName, o.dryRun)
+		return nil
+	}
+	fmt.Fprintf(o.errOut, "csr %q for user %q and groups %q would be created and approved with signer %q (%s dry run)\n", o.csrName, o.userName, o.groups, o.signerName, o.dryRun)
+	return nil
+}
+
+// serverDryRun sends the create and approval of the csr as dry runs,
+// reporting whether the server checked the approval. It usually does not:
+// the dry-run create does not persist the csr to approve.
+func (o *CertOptions) serverDryRun(request []byte) (bool, error) {
+	csr, err := o.createCertificatesV1CertificateSigningRequest(request)
+	if apierrors.IsAlreadyExists(err) {
+		existing, err := o.getCertificateSigningRequest()
+		if err != nil {
+			return false, err
+		}
+		if o.failOnExisting {
+			return false, existingError("csr", existing)
+		}
+		fmt.Fprintf(o.errOut, "csr %q already exists and would be replaced.\n", o.csrName)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = o.approveCertificate(csr)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (o *CertOptions) dryRunOption() []string {
+	if o.dryRun == dryRunServer {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+func approvalCondition(reason string, message string) certificatesv1.CertificateSigningRequestCondition {
+	return certificatesv1.CertificateSigningRequestCondition{
+		Type:    certificatesv1.CertificateApproved,
+		Status:  corev1.ConditionTrue,
+		Message: message,
+		Reason:  reason,
+	}
+}
+
+// IsKconfigApproval reports whether the condition is an approval set by
+// kconfig, including the reason used by earlier versions.
+func IsKconfigApproval(c certificatesv1.CertificateSigningRequestCondition) bool {
+	return c.Type == certificatesv1.CertificateApproved &&
+		(c.Reason == ReasonKconfigCertApprove || c.Reason == reasonKonfigCertApprove)
+}
+
+func (o *CertOptions) confirmApproval(csr *certificatesv1.CertificateSigningRequest) error {
+	fmt.Fprintf(o.errOut, "Name:       %s\n", csr.Name)
+	fmt.Fprintf(o.errOut, "Username:   %s\n", csr.Spec.Username)
+	fmt.Fprintf(o.errOut, "Groups:     %s\n", strings.Join(csr.Spec.Groups, ", "))
+	fmt.Fprintf(o.errOut, "SignerName: %s\n", csr.Spec.SignerName)
+	fmt.Fprintf(o.errOut, "Usages:     %s\n", csr.Spec.Usages)
+
+	if o.yes {
+		return nil
+	}
+
+	if !inputTerminal(o.in) {
+		return o.deleteDeclined(fmt.Errorf("--%s needs an interactive terminal, use --%s to approve non-interactively", flagConfirmApprove, flagYes))
+	}
+
+	fmt.Fprintf(o.errOut, "Approve csr %q? [y/N]: ", csr.Name)
+	answer, _ := bufio.NewReader(o.in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+
+	return o.deleteDeclined(fmt.Errorf("approval of csr %q declined", csr.Name))
+}
+
+// deleteDeclined deletes the csr whose approval was declined, adding a
+// failed delete to err.
+func (o *CertOptions) deleteDeclined(err error) error {
+	if deleteErr := o.deleteCertificatesV1CertificateSigningRequest(); deleteErr != nil && !apierrors.IsNotFound(deleteErr) {
+		return fmt.Errorf("%v - unable to delete csr %q: %v", err, o.csrName, deleteErr)
+	}
+	return err
+}
+
+// inputTerminal reports whether the confirmation is read from a terminal,
+// swapped by tests.
+var inputTerminal = func(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && isTerminal(f)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// runRequestJSON prints the spec of the csr as json for signing apis outside
+// the cluster.
+func (o *CertOptions) runRequestJSON() error {
+	request := o.request
+	if len(o.requestFrom) == 0 {
+		key, csrPem, err := o.createCertificateRequest()
+		if err != nil {
+			return err
+		}
+		err = o.writeKeyOut(key)
+		if err != nil {
+			return err
+		}
+		request = csrPem
+	}
+
+	content, err := json.Marshal(o.certificateSigningRequest(request).Spec)
+	if err != nil {
+		return err
+	}
+	err = writeOutput(o.out, append(content, '\n'), o.output, o.outputFileMode)
+	if err != nil {
+		return err
+	}
+	return o.chownOutputs()
+}
+
+func (o *CertOptions) runOffline() error {
+	key, request, err := o.createCertificateRequest()
+	if err != nil {
+		return err
+	}
+
+	err = o.writeKeyOut(key)
+	if err != nil {
+		return err
+	}
+
+	if o.requestOnly {
+		request = []byte(base64.StdEncoding.EncodeToString(request) + "\n")
+	}
+
+	err = writeOutput(o.out, request, o.output, o.outputFileMode)
+	if err != nil {
+		return err
+	}
+	return o.chownOutputs()
+}
+
+func (o *CertOptions) runWatchExisting() error {
+	_, err := o.getCertificateSigningRequest()
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("no csr %q found for user %q and groups %q, nothing to watch", o.csrName, o.userName, o.groups)
+	}
+	if err != nil {
+		return err
+	}
+
+	csr, err := o.waitForCertificate()
+	if err != nil {
+		return err
+	}
+
+	err = o.writeKubeconfig(o.key, csr.Status.Certificate)
+	if err != nil {
+		return err
+	}
+
+	if o.deleteExisting {
+		klog.V(2).InfoS("delete csr", "csr", o.csrName, "phase", "delete", "duration", time.Since(o.start))
+		return o.deleteCertificatesV1CertificateSigningRequest()
+	}
+
+	return nil
+}
+
+func (o *CertOptions) writeKubeconfig(key []byte, cert []byte) error {
+	clusterName, cluster, err := o.sourceCluster()
+	if err != nil {
+		return err
+	}
+	if len(o.caChain) != 0 {
+		cert, err = appendCAChain(cert, o.caChain)
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.clusterName) != 0 {
+		clusterName = o.clusterName
+	}
+
+	kubeconfig := newKubeconfig(clusterName, cluster, o.userName, o.contextNamespace(), key, cert)
+	if len(o.additionalClusters) != 0 {
+		err = o.addAdditionalClusters(&kubeconfig)
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.description) != 0 {
+		for _, ctx := range kubeconfig.Contexts {
+			err = describeContext(ctx, o.description, o.start)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if o.referenceFiles {
+		err = referenceFiles(kubeconfig.AuthInfos[o.userName], o.keyOut, o.certOut)
+		if err != nil {
+			return err
+		}
+	}
+	if o.noEmbedKey {
+		authInfo := kubeconfig.AuthInfos[o.userName]
+		authInfo.ClientKeyData = nil
+		authInfo.ClientKey = o.keyRefPath
+	}
+
+	if o.store == storeKeychain {
+		err = storeInKeychain(o.keychain, kubeconfig.AuthInfos[o.userName], kubeconfig.CurrentContext)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(o.keyOut) != 0 && len(key) != 0 {
+		err := o.writeKeyOut(key)
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.certOut) != 0 {
+		err := cmdutil.WriteFile(o.certOut, cert, certFileMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = validateKubeconfig(kubeconfig, len(key) == 0 || o.noEmbedKey)
+	if err != nil {
+		return err
+	}
+	if o.merge {
+		err = o.mergeKubeconfig(kubeconfig)
+	} else {
+		err = o.writeStandaloneKubeconfig(kubeconfig)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(key) != 0 && !o.referenceFiles && !o.noEmbedKey && o.store != storeKeychain {
+		o.warnReadableKubeconfig()
+	}
+
+	if len(o.serialOut) != 0 {
+		serial, err := certificateSerial(cert)
+		if err != nil {
+			return fmt.Errorf("--%s: %v", flagSerialOut, err)
+		}
+		err = cmdutil.WriteFile(o.serialOut, []byte(serial+"\n"), certFileMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(o.auditOut) != 0 || o.auditFormat == auditFormatText {
+		err = o.writeAudit(cert)
+		if err != nil {
+			return err
+		}
+	}
+
+	// after the last file is written, the audit record included
+	err = o.chownOutputs()
+	if err != nil {
+		return err
+	}
 
-func NewCmdCert(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
-	o := CertOptions{
-		configAccess: clientcmd.NewDefaultPathOptions(),
+	if len(o.summary) != 0 {
+		err = o.printSummary(clusterName, cluster.Server, cert)
+		if err != nil {
+			return err
+		}
 	}
 
-	cmd := &cobra.Command{
-		Use:   "cert",
-		Short: "Create kubeconfig file with a specified certificate resources.",
-		Run: func(cmd *cobra.Command, args []string) {
-			cmdutil.CheckErr(o.Complete(configFlags))
-			cmdutil.CheckErr(o.Validate())
-			cmdutil.CheckErr(o.Run())
-		},
+	if o.verifyLogin {
+		err = o.reportLogin(kubeconfig)
+		if err != nil {
+			return err
+		}
 	}
 
-	cmd.Flags().StringVarP(&o.userName, flagUserName, "u", "", "user name")
-	cmd.MarkFlagRequired(flagUserName)
-	cmd.Flags().StringArrayVarP(&o.groups, flagGroups, "g", nil, "group name")
-	cmd.MarkFlagRequired(flagGroups)
-	cmd.Flags().StringVarP(&o.output, flagOutput, "o", "", "output file - default stdout")
+	if len(o.postHook) != 0 {
+		return o.runPostHook(cert)
+	}
 
-	return cmd
+	return nil
 }
 
-func (o *CertOptions) Complete(configFlags *genericclioptions.ConfigFlags) error {
-	o.csrName = o.userName + ":" + strings.Join(o.groups, ":")
+// existingContext reports whether the destination kubeconfig has the context
+// kconfig would write, for the same user and cluster, with a client
+// certificate that has not expired.
+func (o *CertOptions) existingContext() (string, bool, error) {
+	clusterName, _, err := o.sourceCluster()
+	if err != nil {
+		return "", false, err
+	}
+	if len(o.clusterName) != 0 {
+		clusterName = o.clusterName
+	}
+	name := o.userName + "@" + clusterName
+
+	var config *clientcmdapi.Config
+	switch {
+	case o.merge && len(o.mergeInto) != 0:
+		config, err = clientcmd.LoadFromFile(o.mergeInto)
+		if os.IsNotExist(err) {
+			return name, false, nil
+		}
+	case o.merge:
+		config, err = loadStartingConfig(o.configAccess)
+	case len(o.output) != 0:
+		config, err = clientcmd.LoadFromFile(o.output)
+		if os.IsNotExist(err) {
+			return name, false, nil
+		}
+	default:
+		return name, false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
 
-	config, err := configFlags.ToRESTConfig()
+	ctx, ok := config.Contexts[name]
+	if !ok || ctx.Cluster != clusterName || ctx.AuthInfo != o.userName {
+		return name, false, nil
+	}
+	authInfo, ok := config.AuthInfos[ctx.AuthInfo]
+	if !ok {
+		return name, false, nil
+	}
+	cert, err := clientCertificate(authInfo)
 	if err != nil {
-		return err
+		return name, false, nil
 	}
-	o.clientSet, err = clientset.NewForConfig(config)
+	organizations := o.organizations()
+	if cert.Subject.CommonName != o.subjectCommonName() || len(subtract(cert.Subject.Organization, organizations)) != 0 || len(subtract(organizations, cert.Subject.Organization)) != 0 {
+		return name, false, nil
+	}
+	return name, !needsRenewal(cert, o.renewBefore), nil
+}
+
+// addAdditionalClusters adds the --additional-cluster clusters of the
+// starting config, each with a context for the same user.
+func (o *CertOptions) addAdditionalClusters(kubeconfig *clientcmdapi.Config) error {
+	startingConfig, err := loadStartingConfig(o.configAccess)
 	if err != nil {
 		return err
 	}
+
+	for _, name := range o.additionalClusters {
+		cluster, ok := startingConfig.Clusters[name]
+		if !ok {
+			return fmt.Errorf("--%s %q not found in kubeconfig", flagAdditionalCluster, name)
+		}
+		kubeconfig.Clusters[name] = cluster
+		kubeconfig.Contexts[o.userName+"@"+name] = &clientcmdapi.Context{
+			Cluster:   name,
+			AuthInfo:  o.userName,
+			Namespace: o.contextNamespace(),
+		}
+	}
 	return nil
 }
 
-func (o *CertOptions) Validate() error {
+// configPath returns the absolute path of the file the kubeconfig is written
+// to, or <stdout>.
+func (o *CertOptions) configPath() (string, error) {
+	path := o.output
+	if o.merge {
+		path = o.mergeTarget()
+	}
+	if len(path) == 0 {
+		return "<stdout>", nil
+	}
+	return filepath.Abs(path)
+}
+
+// writeKeyOut writes the PEM private key to --key-out in the --key-format.
+func (o *CertOptions) writeKeyOut(key []byte) error {
+	if o.keyFormat != cmdutilpkix.KeyFormatPKCS8 {
+		signer, err := cmdutilpkix.ParsePrivateKeyPem(key)
+		if err != nil {
+			return err
+		}
+		key, err = cmdutilpkix.PemPKey(signer, o.keyFormat)
+		if err != nil {
+			return fmt.Errorf("--%s: %v", flagKeyFormat, err)
+		}
+	}
+
+	return cmdutil.WriteFile(o.keyOut, key, keyFileMode)
+}
+
+// chown changes the owner of the written files, swapped by tests.
+var chown = os.Chown
+
+// chownOutputs hands the written regular files to --output-owner and
+// --output-group.
+func (o *CertOptions) chownOutputs() error {
+	if len(o.outputOwner) == 0 && len(o.outputGroup) == 0 {
+		return nil
+	}
+
+	names := []string{o.output, o.keyOut, o.certOut, o.serialOut, o.mergeInto, o.auditOut}
+	if o.format == formatSystemdCreds {
+		for _, name := range []string{systemdCredKey, systemdCredCert, systemdCredCA} {
+			names = append(names, filepath.Join(o.outputDir, name))
+		}
+	}
+	for _, name := range names {
+		if len(name) == 0 {
+			continue
+		}
+		if fi, err := os.Stat(name); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		if err := chown(name, o.outputUID, o.outputGID); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (o *CertOptions) Run() error {
-	_, err := o.getCertificateSigningRequest()
-	if err == nil {
-		err := o.deleteCertificatesV1CertificateSigningRequest()
+// warnReadableKubeconfig warns when the written kubeconfig, which embeds a
+// private key, is readable by group or others.
+func (o *CertOptions) warnReadableKubeconfig() {
+	path, mode := o.output, o.outputFileMode
+	if o.merge {
+		path = o.mergeTarget()
+		fi, err := os.Stat(path)
+		if err != nil {
+			return
+		}
+		mode = fi.Mode().Perm()
+	}
+
+	if len(path) != 0 && mode&0077 != 0 {
+		fmt.Fprintf(o.errOut, "WARNING: %s contains a private key and is readable by group or others (mode %04o), restrict it with chmod 600\n", path, mode)
+	}
+}
+
+func (o *CertOptions) writeStandaloneKubeconfig(kubeconfig clientcmdapi.Config) error {
+	if o.format == formatSystemdCreds {
+		return writeSystemdCreds(o.outputDir, kubeconfig)
+	}
+	if o.noCurrentContext {
+		kubeconfig.CurrentContext = ""
+	}
+
+	content, err := encodeKubeconfig(kubeconfig, o.configVersion)
+	if err != nil {
+		return err
+	}
+
+	if o.compact {
+		content = compactKubeconfig(content, o.env)
+	}
+	if o.format == formatSecret {
+		content, err = secretManifest(o.secretName, o.secretNamespace, content)
 		if err != nil {
 			return err
 		}
 	}
 
-	key, request, err := o.createCertificateRequest()
+	return writeOutput(o.out, content, o.output, o.outputFileMode)
+}
+
+func (o *CertOptions) mergeKubeconfig(kubeconfig clientcmdapi.Config) error {
+	if len(o.mergeInto) != 0 {
+		return o.mergeKubeconfigInto(kubeconfig)
+	}
+
+	startingConfig, err := loadStartingConfig(o.configAccess)
 	if err != nil {
 		return err
 	}
-	csr, err := o.createCertificatesV1CertificateSigningRequest(request)
+
+	err = mergeKubeconfig(startingConfig, &kubeconfig, o.onConflict)
 	if err != nil {
 		return err
 	}
 
-	csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
-		{
-			Type:    certificatesv1.CertificateApproved,
-			Status:  corev1.ConditionTrue,
-			Message: "This CSR was approved by kconfig cert approve.",
-			Reason:  "KonfigCertApprove",
-		},
+	err = clientcmd.ModifyConfig(o.configAccess, *startingConfig, true)
+	if err != nil {
+		return err
+	}
+	if o.printMergedConfig {
+		return o.printMerged(startingConfig)
+	}
+	return nil
+}
+
+// mergeKubeconfigInto merges into the --merge-into file, keeping the mode of
+// an existing file.
+func (o *CertOptions) mergeKubeconfigInto(kubeconfig clientcmdapi.Config) error {
+	mode := os.FileMode(keyFileMode)
+	config, err := clientcmd.LoadFromFile(o.mergeInto)
+	switch {
+	case os.IsNotExist(err):
+		config = clientcmdapi.NewConfig()
+	case err != nil:
+		return fmt.Errorf("--%s: %v", flagMergeInto, err)
+	default:
+		if fi, err := os.Stat(o.mergeInto); err == nil {
+			mode = fi.Mode().Perm()
+		}
 	}
 
-	_, err = o.clientSet.CertificatesV1().
-		CertificateSigningRequests().
-		UpdateApproval(context.TODO(), o.csrName, csr, metav1.UpdateOptions{})
+	err = mergeKubeconfig(config, &kubeconfig, o.onConflict)
 	if err != nil {
 		return err
 	}
 
-	klog.V(2).Infof("wait csr:\"%s\" to be approved.", o.csrName)
-	for {
-		csr, err = o.getCertificateSigningRequest()
-		if err != nil {
-			return err
+	content, err := encodeKubeconfig(*config, o.configVersion)
+	if err != nil {
+		return err
+	}
+	err = cmdutil.WriteFile(o.mergeInto, content, mode)
+	if err != nil {
+		return err
+	}
+	if o.printMergedConfig {
+		return o.printMerged(config)
+	}
+	return nil
+}
+
+// mergeTarget returns the kubeconfig file --merge writes to.
+func (o *CertOptions) mergeTarget() string {
+	if len(o.mergeInto) != 0 {
+		return o.mergeInto
+	}
+	return o.configAccess.GetDefaultFilename()
+}
+
+func (o *CertOptions) reportLogin(kubeconfig clientcmdapi.Config) error {
+	userInfo, err := verifyLogin(kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	if userInfo == nil {
+		fmt.Fprintf(o.errOut, "Logged in as %q, the server does not report the authenticated identity.\n", o.subjectCommonName())
+		return nil
+	}
+
+	fmt.Fprintf(o.errOut, "Logged in as %q with groups %q.\n", userInfo.Username, userInfo.Groups)
+	if userInfo.Username != o.subjectCommonName() {
+		fmt.Fprintf(o.errOut, "WARNING: requested user %q, the server sees %q.\n", o.subjectCommonName(), userInfo.Username)
+	}
+	if dropped := subtract(o.groups, userInfo.Groups); len(dropped) != 0 {
+		fmt.Fprintf(o.errOut, "WARNING: requested groups %q are not attributed by the server.\n", dropped)
+	}
+	if added := subtract(userInfo.Groups, o.groups); len(added) != 0 {
+		fmt.Fprintf(o.errOut, "The server adds groups %q.\n", added)
+	}
+	return nil
+}
+
+// subtract returns the values of a missing from b.
+func subtract(a []string, b []string) []string {
+	var missing []string
+	for _, value := range a {
+		if !contains(b, value) {
+			missing = append(missing, value)
 		}
-		if csr.Status.Certificate != nil {
-			break
+	}
+	return missing
+}
+
+// contextNamespace returns the namespace of the first group mapped by
+// --group-namespace, falling back to --namespace and then DefaultNamespace.
+func (o *CertOptions) contextNamespace() string {
+	for _, group := range o.groups {
+		if namespace, ok := o.groupNamespaces[group]; ok {
+			return namespace
+		}
+	}
+	if len(o.namespace) != 0 {
+		return o.namespace
+	}
+	return DefaultNamespace
+}
+
+func (o *CertOptions) sourceCluster() (string, *clientcmdapi.Cluster, error) {
+	if o.inCluster || len(o.rawServer) != 0 {
+		cluster, err := restConfigCluster(o.restConfig)
+		return defaultClusterName, cluster, err
+	}
+
+	startingConfig, err := loadStartingConfig(o.configAccess)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ctx, ok := startingConfig.Contexts[startingConfig.CurrentContext]
+	if !ok {
+		return "", nil, fmt.Errorf("current context %q not found in kubeconfig %s", startingConfig.CurrentContext, strings.Join(o.configAccess.GetLoadingPrecedence(), string(filepath.ListSeparator)))
+	}
+	cluster, ok := startingConfig.Clusters[ctx.Cluster]
+	if !ok {
+		return "", nil, fmt.Errorf("cluster %q of context %q not found in kubeconfig %s", ctx.Cluster, startingConfig.CurrentContext, strings.Join(o.configAccess.GetLoadingPrecedence(), string(filepath.ListSeparator)))
+	}
+	return ctx.Cluster, cluster, nil
+}
+
+func loadStartingConfig(configAccess clientcmd.ConfigAccess) (*clientcmdapi.Config, error) {
+	config, err := configAccess.GetStartingConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load kubeconfig %s: %v - if it uses yaml anchors or other nonstandard yaml, normalize it with `kubectl config view --flatten`",
+			strings.Join(configAccess.GetLoadingPrecedence(), string(filepath.ListSeparator)), err)
+	}
+	// with several files in KUBECONFIG the cluster may come from another file
+	// than the context, resolve its certificate paths against its own file
+	// rather than the working directory or the file written
+	if err := clientcmd.ResolveLocalPaths(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// restConfigCluster returns the server and certificate authority of the
+// rest config as a kubeconfig cluster.
+func restConfigCluster(config *rest.Config) (*clientcmdapi.Cluster, error) {
+	ca := config.TLSClientConfig.CAData
+	if len(ca) == 0 && len(config.TLSClientConfig.CAFile) != 0 {
+		var err error
+		ca, err = os.ReadFile(config.TLSClientConfig.CAFile)
+		if err != nil {
+			return nil, err
 		}
-		time.Sleep(10 * time.Millisecond)
 	}
 
-	startingConfig, err := o.configAccess.GetStartingConfig()
+	return &clientcmdapi.Cluster{
+		Server:                   config.Host,
+		CertificateAuthorityData: ca,
+	}, nil
+}
+
+// referenceFiles replaces the embedded key and certificate of the user by
+// absolute references to the files they are written to.
+func referenceFiles(authInfo *clientcmdapi.AuthInfo, keyFile string, certFile string) error {
+	keyPath, err := filepath.Abs(keyFile)
+	if err != nil {
+		return err
+	}
+	certPath, err := filepath.Abs(certFile)
 	if err != nil {
 		return err
 	}
 
-	ctx := startingConfig.Contexts[startingConfig.CurrentContext]
-	kubeconfig := clientcmdapi.Config{
+	authInfo.ClientKeyData = nil
+	authInfo.ClientCertificateData = nil
+	authInfo.ClientKey = keyPath
+	authInfo.ClientCertificate = certPath
+	return nil
+}
+
+func newKubeconfig(clusterName string, cluster *clientcmdapi.Cluster, userName string, namespace string, key []byte, cert []byte) clientcmdapi.Config {
+	contextName := userName + "@" + clusterName
+	return clientcmdapi.Config{
 		Clusters: map[string]*clientcmdapi.Cluster{
-			ctx.Cluster: startingConfig.Clusters[ctx.Cluster],
+			clusterName: cluster,
 		},
 		AuthInfos: map[string]*clientcmdapi.AuthInfo{
-			o.userName: {
+			userName: {
 				ClientKeyData:         key,
-				ClientCertificateData: csr.Status.Certificate,
+				ClientCertificateData: cert,
 			},
 		},
 		Contexts: map[string]*clientcmdapi.Context{
-			o.userName + "@" + ctx.Cluster: {
-				Cluster:   ctx.Cluster,
-				AuthInfo:  o.userName,
-				Namespace: "default",
+			contextName: {
+				Cluster:   clusterName,
+				AuthInfo:  userName,
+				Namespace: namespace,
 			},
 		},
-		CurrentContext: o.userName + "@" + ctx.Cluster,
+		CurrentContext: contextName,
+	}
+}
+
+// validateKubeconfig catches dangling references, missing servers and
+// unreadable certificate files before a kubeconfig kubectl would reject is
+// written. The client certificates of a keyless kubeconfig, as written with
+// --request-from or --no-embed-key, are not checked: its key is completed by
+// hand or provisioned elsewhere.
+func validateKubeconfig(kubeconfig clientcmdapi.Config, keyless bool) error {
+	if keyless {
+		authInfos := make(map[string]*clientcmdapi.AuthInfo, len(kubeconfig.AuthInfos))
+		for name, authInfo := range kubeconfig.AuthInfos {
+			authInfo = authInfo.DeepCopy()
+			authInfo.ClientCertificate, authInfo.ClientCertificateData = "", nil
+			authInfo.ClientKey = ""
+			authInfos[name] = authInfo
+		}
+		kubeconfig.AuthInfos = authInfos
 	}
 
-	content, err := clientcmd.Write(kubeconfig)
-	if err != nil {
-		return err
+	if err := clientcmd.Validate(kubeconfig); err != nil {
+		return fmt.Errorf("refusing to write an invalid kubeconfig: %v", err)
 	}
+	return nil
+}
 
-	if len(o.output) != 0 {
-		err := os.WriteFile(o.output, content, 0644)
-		if err != nil {
-			return err
-		}
-	} else {
-		fmt.Fprint(os.Stdout, string(content))
+// writeValidatedKubeconfig writes the kubeconfig after checking that it is
+// complete.
+func writeValidatedKubeconfig(kubeconfig clientcmdapi.Config, out io.Writer, output string, mode os.FileMode) error {
+	if err := validateKubeconfig(kubeconfig, false); err != nil {
+		return err
 	}
 
-	klog.V(2).Infof("delete csr `%s`.", o.csrName)
-	err = o.deleteCertificatesV1CertificateSigningRequest()
+	content, err := clientcmd.Write(kubeconfig)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return writeOutput(out, content, output, mode)
+}
+
+// writeOutput writes the content to the output file, or to out without one.
+func writeOutput(out io.Writer, content []byte, output string, mode os.FileMode) error {
+	if len(output) != 0 {
+		return cmdutil.WriteFile(output, content, mode)
+	}
+
+	_, err := out.Write(content)
+	return err
+}
+
+// secretManifest wraps the kubeconfig into the yaml of an opaque secret.
+func secretManifest(name string, namespace string, content []byte) ([]byte, error) {
+	return yaml.Marshal(&corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			secretKeyKubeconfig: content,
+		},
+	})
+}
+
+// compactKubeconfig encodes the kubeconfig onto a single base64 line,
+// optionally as a KUBECONFIG_B64 environment variable assignment.
+func compactKubeconfig(content []byte, env bool) []byte {
+	line := base64.StdEncoding.EncodeToString(content)
+	if env {
+		line = envCompactKubeconfig + "=" + line
+	}
+	return []byte(line + "\n")
 }
 
 func (o *CertOptions) deleteCertificatesV1CertificateSigningRequest() error {
-	gracePeriodSeconds := int64(0)
+	gracePeriodSeconds := o.deleteGracePeriod
 	err := o.clientSet.CertificatesV1().
 		CertificateSigningRequests().
 		Delete(context.TODO(), o.csrName, metav1.DeleteOptions{
 			GracePeriodSeconds: &gracePeriodSeconds,
+			DryRun:             o.dryRunOption(),
 		})
 
 	return err
@@ -192,28 +2349,84 @@ func (o *CertOptions) createCertificatesV1CertificateSigningRequest(request []by
 	csr, err := o.clientSet.
 		CertificatesV1().
 		CertificateSigningRequests().
-		Create(context.TODO(), &certificatesv1.CertificateSigningRequest{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: o.csrName,
-				Annotations: map[string]string{
-					"creator": "kconfig.local.io",
-				},
-			},
-			Spec: certificatesv1.CertificateSigningRequestSpec{
-				Username: o.userName,
-				Groups:   o.groups,
-				Usages: []certificatesv1.KeyUsage{
-					certificatesv1.UsageClientAuth,
-				},
-				Request: request,
-
-				SignerName: "kubernetes.io/kube-apiserver-client",
-			},
-		}, metav1.CreateOptions{})
+		Create(context.TODO(), o.certificateSigningRequest(request), metav1.CreateOptions{
+			DryRun: o.dryRunOption(),
+		})
 
 	return csr, err
 }
 
+// certificateSigningRequest builds the csr of the user for the PEM request.
+func (o *CertOptions) certificateSigningRequest(request []byte) *certificatesv1.CertificateSigningRequest {
+	labels := map[string]string{
+		labelCreatedBy: createdByKconfig,
+	}
+	for key, value := range o.csrLabels {
+		labels[key] = value
+	}
+
+	annotations := map[string]string{}
+	if !o.noCreatorAnnotation {
+		annotations[annotationCreator] = o.creator
+		labels[labelCreator] = o.creator
+	}
+	if len(o.requestProfile) != 0 {
+		annotations[annotationRequestProfile] = o.requestProfile
+	}
+	if o.jit {
+		annotations[annotationBreakGlass] = "true"
+	}
+	if o.recordOperator {
+		if operator := o.authenticatedOperator(); len(operator) != 0 {
+			annotations[annotationIssuedBy] = operator
+		}
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        o.csrName,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username: o.userName,
+			Groups:   o.groups,
+			Usages:   keyUsages(o.usages),
+			Request:  request,
+
+			SignerName:        o.signerName,
+			ExpirationSeconds: o.expirationSeconds(),
+		},
+	}
+}
+
+// clampToMaxExpiration lowers --expiration to --max-expiration with
+// --clamp-expiration, otherwise Validate rejects it. Without --expiration
+// it requests --max-expiration rather than the signer default, which may
+// be longer.
+func (o *CertOptions) clampToMaxExpiration() {
+	if o.maxExpiration > 0 && o.expiration == 0 {
+		klog.V(2).InfoS("requesting --"+flagMaxExpiration, "expiration", o.maxExpiration)
+		o.expiration = o.maxExpiration
+		return
+	}
+	if o.clampExpiration && o.maxExpiration > 0 && o.expiration > o.maxExpiration {
+		klog.Warningf("lowering --%s %s to --%s %s", flagExpiration, o.expiration, flagMaxExpiration, o.maxExpiration)
+		o.expiration = o.maxExpiration
+	}
+}
+
+func (o *CertOptions) expirationSeconds() *int32 {
+	if o.expiration == 0 {
+		return nil
+	}
+	seconds := int32(o.expiration.Seconds())
+	return &seconds
+}
+
 func (o *CertOptions) getCertificateSigningRequest() (*certificatesv1.CertificateSigningRequest, error) {
 	csr, err := o.clientSet.CertificatesV1().
 		CertificateSigningRequests().
@@ -221,8 +2434,66 @@ func (o *CertOptions) getCertificateSigningRequest() (*certificatesv1.Certificat
 	return csr, err
 }
 
+// subject returns the subject of the --request-from csr, the --subject-spec
+// one or that of the csr generated for the user and groups.
+func (o *CertOptions) subject() (pkix.Name, error) {
+	if len(o.requestFrom) != 0 {
+		csr, err := cmdutilpkix.ParseCertificateRequestPem(o.request)
+		if err != nil {
+			return pkix.Name{}, err
+		}
+		return csr.Subject, nil
+	}
+	if o.subjectSpec != nil {
+		return *o.subjectSpec, nil
+	}
+	return cmdutilpkix.Subject(o.subjectCommonName(), o.organizations()), nil
+}
+
+// subjectCommonName returns the common name of the --subject-spec or the
+// --cn-template, the user name without them.
+func (o *CertOptions) subjectCommonName() string {
+	if o.subjectSpec != nil {
+		return o.subjectSpec.CommonName
+	}
+	if len(o.commonName) != 0 {
+		return o.commonName
+	}
+	return o.userName
+}
+
+// organizations returns the subject organizations, those of the
+// --subject-spec or the groups followed by the --subject-group ones.
+func (o *CertOptions) organizations() []string {
+	if o.subjectSpec != nil {
+		return o.subjectSpec.Organization
+	}
+	organizations := append([]string{}, o.groups...)
+	for _, group := range o.subjectGroups {
+		if !contains(organizations, group) {
+			organizations = append(organizations, group)
+		}
+	}
+	return organizations
+}
+
 func (o *CertOptions) createCertificateRequest() (keyPem []byte, csrPem []byte, err error) {
-	key, csr, err := cmdutilpkix.CreateDefaultCertificateRequest(o.userName, o.groups, nil)
+	var key crypto.Signer
+	switch o.keyType {
+	case cmdutilpkix.KeyTypeECDSA:
+		key, err = cmdutilpkix.GenerateECDSAKey(o.curve)
+	default:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject, err := o.subject()
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := cmdutilpkix.CreateCertificateRequestWithSubject(key, subject, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -237,5 +2508,12 @@ func (o *CertOptions) createCertificateRequest() (keyPem []byte, csrPem []byte,
 		return nil, nil, err
 	}
 
+	// catch key or csr encoding regressions before submitting a csr that
+	// could never be used with the key
+	err = cmdutilpkix.VerifyCertificateRequestPem(keyPem, csrPem)
+	if err != nil {
+		return nil, nil, fmt.Errorf("internal error: generated csr fails its self-check: %v", err)
+	}
+
 	return keyPem, csrPem, nil
 }
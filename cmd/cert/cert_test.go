@@ -0,0 +1,2590 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+func TestCompleteInCluster(t *testing.T) {
+	_, caCert, err := cmdutilpkix.CreateSelfSignedCertificate("kubernetes", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := cmdutilpkix.PemCertificate(caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.96.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+	defer func(f func() (*rest.Config, error)) { inClusterConfig = f }(inClusterConfig)
+	inClusterConfig = func() (*rest.Config, error) {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if len(host) == 0 || len(port) == 0 {
+			return nil, rest.ErrNotInCluster
+		}
+		return &rest.Config{
+			Host:            "https://" + net.JoinHostPort(host, port),
+			BearerToken:     "token",
+			TLSClientConfig: rest.TLSClientConfig{CAFile: caFile},
+		}, nil
+	}
+
+	o := CertOptions{userName: "alice", groups: []string{"dev"}, inCluster: true, outputMode: defaultOutputMode}
+	if err := o.Complete(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	clusterName, cluster, err := o.sourceCluster()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if clusterName != defaultClusterName {
+		t.Errorf("cluster name: got %q, want %q", clusterName, defaultClusterName)
+	}
+
+	if cluster.Server != "https://10.96.0.1:443" {
+		t.Errorf("server: got %q, want %q", cluster.Server, "https://10.96.0.1:443")
+	}
+
+	if !reflect.DeepEqual(cluster.CertificateAuthorityData, ca) {
+		t.Error("certificate authority data not matching the service account ca")
+	}
+}
+
+// TestSourceClusterKubeconfigList takes the current context from the second
+// file of KUBECONFIG and its cluster, with a relative certificate authority,
+// from the first.
+func TestSourceClusterKubeconfigList(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	a, b := filepath.Join(dirA, "config"), filepath.Join(dirB, "config")
+	if err := os.WriteFile(filepath.Join(dirA, "ca.crt"), []byte("ca"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := clientcmd.WriteToFile(clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{"prod": {Server: "https://prod:6443", CertificateAuthority: "ca.crt"}},
+	}, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clientcmd.WriteToFile(clientcmdapi.Config{
+		AuthInfos:      map[string]*clientcmdapi.AuthInfo{"admin": {Token: "token"}},
+		Contexts:       map[string]*clientcmdapi.Context{"admin@prod": {Cluster: "prod", AuthInfo: "admin"}},
+		CurrentContext: "admin@prod",
+	}, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(clientcmd.RecommendedConfigPathEnvVar, a+string(filepath.ListSeparator)+b)
+
+	o, _ := newTestCertOptions(t)
+	o.configAccess = clientcmd.NewDefaultPathOptions()
+	if err := o.writeKubeconfig([]byte("key"), []byte("certificate")); err != nil {
+		t.Fatal(err)
+	}
+	config, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, ok := config.Clusters["prod"]
+	if !ok || cluster.Server != "https://prod:6443" {
+		t.Fatalf("got clusters %v, want prod", config.Clusters)
+	}
+	if want := filepath.Join(dirA, "ca.crt"); cluster.CertificateAuthority != want {
+		t.Errorf("certificate authority: got %q, want %q", cluster.CertificateAuthority, want)
+	}
+
+	// merged back into the file of the cluster, relative to it again
+	o.merge = true
+	if err := o.writeKubeconfig([]byte("key"), []byte("certificate")); err != nil {
+		t.Fatal(err)
+	}
+	merged, err := clientcmd.LoadFromFile(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := merged.Contexts["alice@prod"]; !ok {
+		t.Errorf("context alice@prod not merged into %s", a)
+	}
+	if got := merged.Clusters["prod"].CertificateAuthority; got != "ca.crt" {
+		t.Errorf("merged certificate authority: got %q, want %q", got, "ca.crt")
+	}
+}
+
+func newTestConfigAccess(t *testing.T) clientcmd.ConfigAccess {
+	t.Helper()
+
+	config := clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"local": {Server: "https://127.0.0.1:6443"},
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{
+			"admin": {Token: "token"},
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			"admin@local": {Cluster: "local", AuthInfo: "admin"},
+		},
+		CurrentContext: "admin@local",
+	}
+	path := filepath.Join(t.TempDir(), "config")
+	if err := clientcmd.WriteToFile(config, path); err != nil {
+		t.Fatal(err)
+	}
+
+	return &clientcmd.PathOptions{
+		GlobalFile:   path,
+		LoadingRules: &clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+	}
+}
+
+// newTestCertOptions returns options backed by a fake clientset whose
+// signer answers the n-th approval with decisions[n], issuing a
+// certificate once the decisions are exhausted.
+func newTestCertOptions(t *testing.T, decisions ...certificatesv1.RequestConditionType) (*CertOptions, *fake.Clientset) {
+	t.Helper()
+
+	client := fake.NewSimpleClientset()
+	approvals := 0
+	client.PrependReactor("update", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "approval" {
+			return false, nil, nil
+		}
+		csr := action.(k8stesting.UpdateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		if approvals < len(decisions) && decisions[approvals] == certificatesv1.CertificateDenied {
+			csr.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{
+				{Type: certificatesv1.CertificateDenied, Status: corev1.ConditionTrue, Reason: "PolicyDenied"},
+			}
+		} else {
+			csr.Status.Certificate = []byte("certificate")
+		}
+		approvals++
+		return false, nil, nil
+	})
+
+	o := newCertOptions()
+	o.clientSet = client
+	o.configAccess = newTestConfigAccess(t)
+	o.csrName = "alice:dev"
+	o.userName = "alice"
+	o.groups = []string{"dev"}
+	o.output = filepath.Join(t.TempDir(), "alice.config")
+	o.outputFileMode = 0600
+	o.creator = creatorKconfig
+	o.recordOperator = false
+	o.out, o.errOut = io.Discard, io.Discard
+	o.deleteOnSuccess, o.deleteOnSuccessSet = true, true
+	return o, client
+}
+
+func countActions(client *fake.Clientset, verb string) int {
+	n := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == verb && action.GetSubresource() == "" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRunForceRecreateOnDenied(t *testing.T) {
+	var tests = []struct {
+		name      string
+		force     bool
+		decisions []certificatesv1.RequestConditionType
+		creates   int
+		denied    bool
+	}{
+		{
+			name:      "denied without recreate",
+			decisions: []certificatesv1.RequestConditionType{certificatesv1.CertificateDenied},
+			creates:   1,
+			denied:    true,
+		},
+		{
+			name:      "recreated once after a denial",
+			force:     true,
+			decisions: []certificatesv1.RequestConditionType{certificatesv1.CertificateDenied},
+			creates:   2,
+		},
+		{
+			name:  "denied twice",
+			force: true,
+			decisions: []certificatesv1.RequestConditionType{
+				certificatesv1.CertificateDenied,
+				certificatesv1.CertificateDenied,
+			},
+			creates: 2,
+			denied:  true,
+		},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t, test.decisions...)
+		o.forceRecreateOnDenied = test.force
+
+		err := o.Run()
+		if test.denied != errors.Is(err, errCertificateDenied) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+
+		if creates := countActions(client, "create"); creates != test.creates {
+			t.Errorf("%s: creates: got %d, want %d", test.name, creates, test.creates)
+		}
+	}
+}
+
+func TestRunDeniedRetries(t *testing.T) {
+	denied := certificatesv1.CertificateDenied
+	var tests = []struct {
+		name      string
+		retries   int
+		timeout   time.Duration
+		decisions []certificatesv1.RequestConditionType
+		creates   int
+		denied    bool
+	}{
+		{
+			name:      "approved after two denials",
+			retries:   3,
+			decisions: []certificatesv1.RequestConditionType{denied, denied},
+			creates:   3,
+		},
+		{
+			name:      "retries exhausted",
+			retries:   1,
+			decisions: []certificatesv1.RequestConditionType{denied, denied},
+			creates:   2,
+			denied:    true,
+		},
+		{
+			name:      "backoff beyond the timeout",
+			retries:   3,
+			timeout:   20 * time.Millisecond,
+			decisions: []certificatesv1.RequestConditionType{denied, denied},
+			creates:   1,
+			denied:    true,
+		},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t, test.decisions...)
+		o.deniedRetries = test.retries
+		o.deniedBackoff = 50 * time.Millisecond
+		o.timeout = test.timeout
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		err := o.Run()
+		if test.denied != errors.Is(err, errCertificateDenied) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if creates := countActions(client, "create"); creates != test.creates {
+			t.Errorf("%s: creates: got %d, want %d", test.name, creates, test.creates)
+		}
+	}
+}
+
+func TestRenderCSRName(t *testing.T) {
+	var tests = []struct {
+		template string
+		want     string
+	}{
+		{template: "{{.User}}", want: "alice"},
+		{template: "kconfig-{{.User}}-{{index .Groups 1}}", want: "kconfig-alice-ops"},
+	}
+	for _, test := range tests {
+		got, err := renderCSRName(test.template, "alice", []string{"dev", "ops"})
+		if err != nil {
+			t.Errorf("%s: %v", test.template, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.template, got, test.want)
+		}
+	}
+
+	a, _ := renderCSRName("{{.Hash}}", "alice", []string{"dev"})
+	b, _ := renderCSRName("{{.Hash}}", "alice", []string{"ops"})
+	if len(a) != 8 || a == b {
+		t.Errorf("Hash: got %q and %q, want distinct 8 character hashes", a, b)
+	}
+
+	if _, err := renderCSRName("{{.Missing}}", "alice", nil); err == nil {
+		t.Error("expected an error for an unknown template field")
+	}
+}
+
+func TestValidateStrictTLS(t *testing.T) {
+	var tests = []struct {
+		strict   bool
+		insecure bool
+		err      bool
+	}{
+		{strict: false, insecure: true},
+		{strict: true, insecure: false},
+		{strict: true, insecure: true, err: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.strictTLS = test.strict
+		o.restConfig = &rest.Config{
+			Host:            "https://127.0.0.1:6443",
+			TLSClientConfig: rest.TLSClientConfig{Insecure: test.insecure},
+		}
+
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("strict %t, insecure %t: unexpected error %v", test.strict, test.insecure, err)
+		}
+	}
+}
+
+func TestSourceClusterMalformedKubeconfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("apiVersion: v1\nkind: Config\nclusters: [\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	o := CertOptions{
+		configAccess: &clientcmd.PathOptions{
+			GlobalFile:   path,
+			LoadingRules: &clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+		},
+	}
+
+	_, _, err := o.sourceCluster()
+	if err == nil {
+		t.Fatal("expected an error for a malformed kubeconfig")
+	}
+
+	if !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "kubectl config view --flatten") {
+		t.Errorf("error does not name the file or the workaround: %v", err)
+	}
+}
+
+func TestCompactKubeconfig(t *testing.T) {
+	content := []byte("apiVersion: v1\nkind: Config\n")
+
+	var tests = []struct {
+		env    bool
+		prefix string
+	}{
+		{env: false, prefix: ""},
+		{env: true, prefix: envCompactKubeconfig + "="},
+	}
+	for _, test := range tests {
+		got := string(compactKubeconfig(content, test.env))
+		if strings.Count(got, "\n") != 1 || !strings.HasSuffix(got, "\n") {
+			t.Errorf("env %t: got %q, want a single line", test.env, got)
+		}
+
+		if !strings.HasPrefix(got, test.prefix) {
+			t.Errorf("env %t: got %q, want prefix %q", test.env, got, test.prefix)
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(strings.TrimPrefix(got, test.prefix), "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(decoded) != string(content) {
+			t.Errorf("env %t: decoded %q, want %q", test.env, decoded, content)
+		}
+	}
+}
+
+func TestContextNamespace(t *testing.T) {
+	var tests = []struct {
+		groups          []string
+		namespace       string
+		groupNamespaces map[string]string
+		want            string
+	}{
+		{groups: []string{"dev"}, want: DefaultNamespace},
+		{groups: []string{"qa"}, groupNamespaces: map[string]string{"dev": "development"}, want: DefaultNamespace},
+		{groups: []string{"dev"}, namespace: "team", want: "team"},
+		{
+			groups:          []string{"ops", "dev"},
+			namespace:       "team",
+			groupNamespaces: map[string]string{"dev": "development", "ops": "operations"},
+			want:            "operations",
+		},
+		{
+			groups:          []string{"qa"},
+			namespace:       "team",
+			groupNamespaces: map[string]string{"dev": "development"},
+			want:            "team",
+		},
+	}
+	for _, test := range tests {
+		o := CertOptions{groups: test.groups, namespace: test.namespace, groupNamespaces: test.groupNamespaces}
+		if got := o.contextNamespace(); got != test.want {
+			t.Errorf("groups %q: got %q, want %q", test.groups, got, test.want)
+		}
+	}
+}
+
+func TestRunApproveReason(t *testing.T) {
+	o, client := newTestCertOptions(t)
+
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	approvals := 0
+	for _, action := range client.Actions() {
+		if action.GetSubresource() != "approval" {
+			continue
+		}
+		approvals++
+
+		csr := action.(k8stesting.UpdateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		c := csr.Status.Conditions[0]
+		if c.Reason != ReasonKconfigCertApprove {
+			t.Errorf("reason: got %q, want %q", c.Reason, ReasonKconfigCertApprove)
+		}
+		if !IsKconfigApproval(c) {
+			t.Error("approval condition not recognized as a kconfig approval")
+		}
+	}
+
+	if approvals != 1 {
+		t.Errorf("approvals: got %d, want 1", approvals)
+	}
+}
+
+func TestIsKconfigApproval(t *testing.T) {
+	var tests = []struct {
+		condition certificatesv1.CertificateSigningRequestCondition
+		want      bool
+	}{
+		{
+			condition: certificatesv1.CertificateSigningRequestCondition{Type: certificatesv1.CertificateApproved, Reason: ReasonKconfigCertApprove},
+			want:      true,
+		},
+		{
+			condition: certificatesv1.CertificateSigningRequestCondition{Type: certificatesv1.CertificateApproved, Reason: "KonfigCertApprove"},
+			want:      true,
+		},
+		{
+			condition: certificatesv1.CertificateSigningRequestCondition{Type: certificatesv1.CertificateApproved, Reason: "AutoApproved"},
+		},
+		{
+			condition: certificatesv1.CertificateSigningRequestCondition{Type: certificatesv1.CertificateDenied, Reason: ReasonKconfigCertApprove},
+		},
+	}
+	for _, test := range tests {
+		if got := IsKconfigApproval(test.condition); got != test.want {
+			t.Errorf("%s/%s: got %t, want %t", test.condition.Type, test.condition.Reason, got, test.want)
+		}
+	}
+}
+
+func TestValidateRequestFrom(t *testing.T) {
+	key, request, err := (&CertOptions{userName: "alice", keyType: cmdutilpkix.KeyTypeRSA}).createCertificateRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := (&CertOptions{userName: "bob", keyType: cmdutilpkix.KeyTypeECDSA, curve: cmdutilpkix.CurveP256}).createCertificateRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name    string
+		request []byte
+		key     []byte
+		err     bool
+	}{
+		{name: "request only", request: request},
+		{name: "matching key", request: request, key: key},
+		{name: "mismatching key", request: request, key: otherKey, err: true},
+		{name: "not a csr", request: key, err: true},
+	}
+	for _, test := range tests {
+		o := CertOptions{requestFrom: "-", request: test.request, key: test.key}
+		err := o.validateRequest()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestRunRequestFrom(t *testing.T) {
+	_, request, err := (&CertOptions{userName: "alice", keyType: cmdutilpkix.KeyTypeRSA}).createCertificateRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, client := newTestCertOptions(t)
+	o.requestFrom = "-"
+	o.request = request
+
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "create" {
+			continue
+		}
+		csr := action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		if !reflect.DeepEqual(csr.Spec.Request, request) {
+			t.Error("csr spec request is not the --request-from csr")
+		}
+	}
+
+	config, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.AuthInfos["alice"].ClientKeyData) != 0 {
+		t.Error("kubeconfig embeds a key without --key-file")
+	}
+}
+
+func TestRunOutputMode(t *testing.T) {
+	var tests = []struct {
+		mode os.FileMode
+	}{
+		{mode: 0600},
+		{mode: 0640},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.outputFileMode = test.mode
+		o.keyOut = filepath.Join(t.TempDir(), "alice.key")
+
+		// an existing file keeps its mode on os.WriteFile
+		if err := os.WriteFile(o.output, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		for path, want := range map[string]os.FileMode{o.output: test.mode, o.keyOut: keyFileMode} {
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Mode().Perm() != want {
+				t.Errorf("%s: got mode %o, want %o", filepath.Base(path), fi.Mode().Perm(), want)
+			}
+		}
+	}
+}
+
+func TestRunWarnReadableKubeconfig(t *testing.T) {
+	var tests = []struct {
+		mode os.FileMode
+		warn bool
+	}{
+		{mode: 0600},
+		{mode: 0644, warn: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.outputFileMode = test.mode
+		var errOut strings.Builder
+		o.errOut = &errOut
+
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		if warned := strings.Contains(errOut.String(), "WARNING"); warned != test.warn {
+			t.Errorf("mode %o: got warning %t, want %t", test.mode, warned, test.warn)
+		}
+	}
+}
+
+func TestRunReferenceFiles(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	dir := t.TempDir()
+	o.referenceFiles = true
+	o.keyOut = filepath.Join(dir, "alice.key")
+	o.certOut = filepath.Join(dir, "alice.crt")
+
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authInfo := config.AuthInfos["alice"]
+	if len(authInfo.ClientKeyData) != 0 || len(authInfo.ClientCertificateData) != 0 {
+		t.Error("kubeconfig embeds key or certificate data")
+	}
+	if authInfo.ClientKey != o.keyOut {
+		t.Errorf("client-key: got %q, want %q", authInfo.ClientKey, o.keyOut)
+	}
+	if authInfo.ClientCertificate != o.certOut {
+		t.Errorf("client-certificate: got %q, want %q", authInfo.ClientCertificate, o.certOut)
+	}
+
+	cert, err := os.ReadFile(o.certOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cert) != "certificate" {
+		t.Errorf("certificate file: got %q", cert)
+	}
+}
+
+func TestRunNoEmbedKey(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	o.noEmbedKey = true
+	if err := o.Validate(); err == nil {
+		t.Error("accepted --no-embed-key without --key-ref-path")
+	}
+	o.keyRefPath = "/etc/kubernetes/pki/alice.key"
+	if err := o.Validate(); err == nil {
+		t.Error("accepted --no-embed-key without --key-out for the generated key")
+	}
+	o.keyOut = filepath.Join(t.TempDir(), "alice.key")
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authInfo := config.AuthInfos["alice"]
+	if len(authInfo.ClientKeyData) != 0 || authInfo.ClientKey != o.keyRefPath {
+		t.Errorf("got client-key %q with %d bytes of key data, want %q only", authInfo.ClientKey, len(authInfo.ClientKeyData), o.keyRefPath)
+	}
+	if string(authInfo.ClientCertificateData) != "certificate" {
+		t.Errorf("got client-certificate-data %q, want the certificate embedded", authInfo.ClientCertificateData)
+	}
+	if _, err := os.Stat(o.keyOut); err != nil {
+		t.Errorf("generated key not kept: %v", err)
+	}
+
+	o, _ = newTestCertOptions(t)
+	o.keyRefPath = "/etc/kubernetes/pki/alice.key"
+	if err := o.Validate(); err == nil {
+		t.Error("accepted --key-ref-path without --no-embed-key")
+	}
+
+	var tests = []struct {
+		name   string
+		modify func(o *CertOptions)
+	}{
+		{name: "systemd-creds", modify: func(o *CertOptions) {
+			o.output, o.outputDir, o.format = "", t.TempDir(), formatSystemdCreds
+		}},
+		{name: "verify-login", modify: func(o *CertOptions) { o.verifyLogin = true }},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.noEmbedKey = true
+		o.keyRefPath = "/etc/kubernetes/pki/alice.key"
+		o.keyOut = filepath.Join(t.TempDir(), "alice.key")
+		test.modify(o)
+		if err := o.Validate(); err == nil || !strings.Contains(err.Error(), flagNoEmbedKey) {
+			t.Errorf("%s: got %v, want --%s rejected", test.name, err, flagNoEmbedKey)
+		}
+	}
+}
+
+func TestRunDeleteGracePeriod(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.deleteGracePeriod = 30
+	if err := client.Tracker().Add(&certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: o.csrName}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	deletes := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "delete" {
+			continue
+		}
+		deletes++
+
+		options := action.(k8stesting.DeleteActionImpl).DeleteOptions
+		if options.GracePeriodSeconds == nil || *options.GracePeriodSeconds != 30 {
+			t.Errorf("delete grace period: got %v, want 30", options.GracePeriodSeconds)
+		}
+	}
+
+	// the pre-run and the post-run delete
+	if deletes != 2 {
+		t.Errorf("deletes: got %d, want 2", deletes)
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	var tests = []struct {
+		namespace string
+		reactor   k8stesting.ReactionFunc
+		err       bool
+	}{
+		{namespace: "development"},
+		{namespace: "typo", err: true},
+		{
+			namespace: "typo",
+			reactor: func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, apierrors.NewForbidden(corev1.Resource("namespaces"), "typo", errors.New("no access"))
+			},
+		},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.validateNamespace = true
+		o.namespace = test.namespace
+		if err := client.Tracker().Add(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "development"}}); err != nil {
+			t.Fatal(err)
+		}
+		if test.reactor != nil {
+			client.PrependReactor("get", "namespaces", test.reactor)
+		}
+
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.namespace, err)
+		}
+	}
+}
+
+func TestRunAuditOut(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	o.auditOut = filepath.Join(t.TempDir(), "record.json")
+
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(o.auditOut); !os.IsNotExist(err) {
+		t.Fatalf("validate left %s behind: %v", o.auditOut, err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(o.auditOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record auditRecord
+	if err := json.Unmarshal(content, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Username != "alice" || record.CSRName != "alice:dev" || record.Operator != "admin" {
+		t.Errorf("unexpected audit record %+v", record)
+	}
+}
+
+func TestRunAuditFormatText(t *testing.T) {
+	var tests = []struct {
+		name     string
+		auditOut bool
+	}{
+		{name: "stderr"},
+		{name: "audit out", auditOut: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.auditFormat = auditFormatText
+		var errOut bytes.Buffer
+		o.errOut = &errOut
+		if test.auditOut {
+			o.auditOut = filepath.Join(t.TempDir(), "record.txt")
+		}
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		got := errOut.String()
+		if test.auditOut {
+			content, err := os.ReadFile(o.auditOut)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = string(content)
+		}
+		for _, want := range []string{"User:     alice\n", "Groups:   dev\n", "CSR:      alice:dev\n", "Operator: admin\n"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: %q not in %q", test.name, want, got)
+			}
+		}
+	}
+
+	for _, format := range []string{"yaml", ""} {
+		o, _ := newTestCertOptions(t)
+		o.auditFormat = format
+		if err := o.Validate(); err == nil {
+			t.Errorf("accepted --audit-format %q", format)
+		}
+	}
+}
+
+func TestValidateAllowedGroups(t *testing.T) {
+	var tests = []struct {
+		groups  []string
+		allowed []string
+		err     bool
+	}{
+		{groups: []string{"dev", "system:masters"}},
+		{groups: []string{"dev"}, allowed: []string{"dev", "ops"}},
+		{groups: []string{"dev", "system:masters"}, allowed: []string{"dev", "ops"}, err: true},
+	}
+	for _, test := range tests {
+		err := validateAllowedGroups(test.groups, test.allowed)
+		if test.err != (err != nil) {
+			t.Errorf("%q allowing %q: unexpected error %v", test.groups, test.allowed, err)
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.dryRun = dryRunClient
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(client.Actions()); n != 0 {
+		t.Errorf("client dry run: got %d api calls, want none", n)
+	}
+	if _, err := os.Stat(o.output); !os.IsNotExist(err) {
+		t.Errorf("client dry run wrote %s", o.output)
+	}
+}
+
+func TestRunServerDryRun(t *testing.T) {
+	const csrsPath = "/apis/certificates.k8s.io/v1/certificatesigningrequests"
+
+	var tests = []struct {
+		name           string
+		existing       bool
+		failOnExisting bool
+		approval       int
+		want           string
+		wantDryRuns    []string
+		err            bool
+	}{
+		{
+			name:        "approval not kept",
+			approval:    http.StatusNotFound,
+			want:        "would be created with signer \"kubernetes.io/kube-apiserver-client\", its approval was not checked",
+			wantDryRuns: []string{"POST " + csrsPath, "PUT " + csrsPath + "/alice:dev/approval"},
+		},
+		{
+			name:        "approval checked",
+			approval:    http.StatusOK,
+			want:        "would be created and approved",
+			wantDryRuns: []string{"POST " + csrsPath, "PUT " + csrsPath + "/alice:dev/approval"},
+		},
+		{
+			name:        "existing",
+			existing:    true,
+			want:        "already exists and would be replaced",
+			wantDryRuns: []string{"POST " + csrsPath},
+		},
+		{
+			name:           "fail on existing",
+			existing:       true,
+			failOnExisting: true,
+			err:            true,
+		},
+	}
+	for _, test := range tests {
+		var dryRuns []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				if dryRun := r.URL.Query()["dryRun"]; !reflect.DeepEqual(dryRun, []string{metav1.DryRunAll}) {
+					t.Errorf("%s: %s %s: got dryRun %q, want [All]", test.name, r.Method, r.URL.Path, dryRun)
+				}
+				dryRuns = append(dryRuns, r.Method+" "+r.URL.Path)
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			resource := certificatesv1.Resource("certificatesigningrequests")
+			switch {
+			case r.Method == http.MethodPost && test.existing:
+				writeStatus(w, apierrors.NewAlreadyExists(resource, "alice:dev"))
+			case r.Method == http.MethodGet:
+				csr := &certificatesv1.CertificateSigningRequest{
+					TypeMeta:   metav1.TypeMeta{APIVersion: "certificates.k8s.io/v1", Kind: "CertificateSigningRequest"},
+					ObjectMeta: metav1.ObjectMeta{Name: "alice:dev"},
+				}
+				json.NewEncoder(w).Encode(csr)
+			case r.Method == http.MethodPut && test.approval == http.StatusNotFound:
+				writeStatus(w, apierrors.NewNotFound(resource, "alice:dev"))
+			default:
+				w.Write(body)
+			}
+		}))
+
+		o, _ := newTestCertOptions(t)
+		o.clientSet = kubernetes.NewForConfigOrDie(&rest.Config{Host: server.URL})
+		o.dryRun = dryRunServer
+		o.failOnExisting = test.failOnExisting
+		var errOut bytes.Buffer
+		o.errOut = &errOut
+
+		err := o.Run()
+		server.Close()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(errOut.String(), test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, errOut.String(), test.want)
+		}
+		if !reflect.DeepEqual(dryRuns, test.wantDryRuns) {
+			t.Errorf("%s: got dry-run calls %q, want %q", test.name, dryRuns, test.wantDryRuns)
+		}
+		if _, err := os.Stat(o.output); !os.IsNotExist(err) {
+			t.Errorf("%s: dry run wrote %s", test.name, o.output)
+		}
+	}
+}
+
+// writeStatus writes the api error as the status response of the apiserver.
+func writeStatus(w http.ResponseWriter, err error) {
+	status := err.(apierrors.APIStatus).Status()
+	status.APIVersion, status.Kind = "v1", "Status"
+	w.WriteHeader(int(status.Code))
+	json.NewEncoder(w).Encode(status)
+}
+
+func TestRunConfirmApprove(t *testing.T) {
+	defer func(f func(io.Reader) bool) { inputTerminal = f }(inputTerminal)
+
+	var tests = []struct {
+		name        string
+		answer      string
+		terminal    bool
+		deleteFails bool
+		approvals   int
+		deletes     int
+		err         string
+	}{
+		{name: "confirmed", answer: "y\n", terminal: true, approvals: 1, deletes: 1},
+		{name: "declined", answer: "n\n", terminal: true, deletes: 1, err: "declined"},
+		{name: "no terminal", answer: "y\n", deletes: 1, err: "interactive terminal"},
+		{name: "declined delete fails", answer: "\n", terminal: true, deleteFails: true, deletes: 1, err: "unable to delete csr"},
+	}
+	for _, test := range tests {
+		inputTerminal = func(io.Reader) bool { return test.terminal }
+
+		o, client := newTestCertOptions(t)
+		o.confirmApprove = true
+		o.in = strings.NewReader(test.answer)
+		o.errOut = io.Discard
+		if test.deleteFails {
+			client.PrependReactor("delete", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("connection refused")
+			})
+		}
+
+		err := o.Run()
+		if len(test.err) == 0 && err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if len(test.err) != 0 && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+		}
+		approvals := 0
+		for _, action := range client.Actions() {
+			if action.Matches("update", "certificatesigningrequests") && action.GetSubresource() == "approval" {
+				approvals++
+			}
+		}
+		if approvals != test.approvals {
+			t.Errorf("%s: got %d approvals, want %d", test.name, approvals, test.approvals)
+		}
+		if n := countActions(client, "delete"); n != test.deletes {
+			t.Errorf("%s: got %d deletes, want %d", test.name, n, test.deletes)
+		}
+	}
+}
+
+func TestDefaultCreator(t *testing.T) {
+	t.Setenv(envCreator, "")
+	if got := defaultCreator(); got != creatorKconfig {
+		t.Errorf("got %q, want %q", got, creatorKconfig)
+	}
+
+	t.Setenv(envCreator, "team-a.example.com")
+	if got := defaultCreator(); got != "team-a.example.com" {
+		t.Errorf("got %q, want %q", got, "team-a.example.com")
+	}
+}
+
+func TestValidateCreator(t *testing.T) {
+	var tests = []struct {
+		creator string
+		err     bool
+	}{
+		{creator: creatorKconfig},
+		{creator: "", err: true},
+		{creator: "team a", err: true},
+	}
+	for _, test := range tests {
+		err := validateCreator(test.creator)
+		if test.err != (err != nil) {
+			t.Errorf("%q: unexpected error %v", test.creator, err)
+		}
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+
+	o.output = "alice.config"
+	want, err := filepath.Abs("alice.config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := o.configPath(); err != nil || got != want {
+		t.Errorf("output: got %q %v, want %q", got, err, want)
+	}
+
+	o.output = ""
+	if got, err := o.configPath(); err != nil || got != "<stdout>" {
+		t.Errorf("stdout: got %q %v, want %q", got, err, "<stdout>")
+	}
+
+	o.merge = true
+	if got, err := o.configPath(); err != nil || got != o.configAccess.GetDefaultFilename() {
+		t.Errorf("merge: got %q %v, want %q", got, err, o.configAccess.GetDefaultFilename())
+	}
+}
+
+func TestRunAdditionalCluster(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	config, err := loadStartingConfig(o.configAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Clusters["edge"] = &clientcmdapi.Cluster{Server: "https://10.0.0.1:6443"}
+	if err := clientcmd.ModifyConfig(o.configAccess, *config, false); err != nil {
+		t.Fatal(err)
+	}
+
+	o.additionalClusters = []string{"missing"}
+	if err := o.Validate(); err == nil {
+		t.Error("expected an error for a missing additional cluster")
+	}
+
+	o.additionalClusters = []string{"edge"}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	kubeconfig, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kubeconfig.CurrentContext != "alice@local" {
+		t.Errorf("current context: got %q, want %q", kubeconfig.CurrentContext, "alice@local")
+	}
+	ctx, ok := kubeconfig.Contexts["alice@edge"]
+	if !ok || ctx.Cluster != "edge" || ctx.AuthInfo != "alice" {
+		t.Errorf("unexpected additional context %+v", ctx)
+	}
+	if cluster := kubeconfig.Clusters["edge"]; cluster == nil || cluster.Server != "https://10.0.0.1:6443" {
+		t.Errorf("unexpected additional cluster %+v", cluster)
+	}
+}
+
+func TestRenderFilename(t *testing.T) {
+	var tests = []struct {
+		template string
+		want     string
+		err      bool
+	}{
+		{template: defaultFilenameTemplate, want: "alice.kubeconfig"},
+		{template: `{{.User}}-{{join .Groups "-"}}.yaml`, err: true},
+		{template: `{{.User}}-{{index .Groups 0}}.yaml`, want: "alice-dev.yaml"},
+		{template: "../{{.User}}", err: true},
+		{template: "..", err: true},
+		{template: "{{.Team}}", err: true},
+	}
+	for _, test := range tests {
+		got, err := renderFilename(test.template, "alice", []string{"dev", "ops"})
+		if test.err != (err != nil) {
+			t.Errorf("%q: unexpected error %v", test.template, err)
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.template, got, test.want)
+		}
+	}
+}
+
+func TestRenderCommonName(t *testing.T) {
+	var tests = []struct {
+		template string
+		want     string
+		err      bool
+	}{
+		{template: "{{.User}}@example.com", want: "alice@example.com"},
+		{template: "oidc:{{.User}}", want: "oidc:alice"},
+		{template: "{{.Team}}", err: true},
+		{template: " ", err: true},
+		{template: "{{.User}}\n", err: true},
+		{template: strings.Repeat("a", 60) + "{{.User}}", err: true},
+	}
+	for _, test := range tests {
+		got, err := renderCommonName(test.template, "alice", []string{"dev"})
+		if test.err != (err != nil) {
+			t.Errorf("%q: unexpected error %v", test.template, err)
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.template, got, test.want)
+		}
+	}
+}
+
+func TestRunCNTemplate(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.commonName = "alice@example.com"
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.Matches("create", "certificatesigningrequests") {
+			created := action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+			csr, err := cmdutilpkix.ParseCertificateRequestPem(created.Spec.Request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if csr.Subject.CommonName != "alice@example.com" {
+				t.Errorf("got common name %q, want alice@example.com", csr.Subject.CommonName)
+			}
+		}
+	}
+	config, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config.AuthInfos["alice"]; !ok || config.CurrentContext != "alice@local" {
+		t.Errorf("got users %v and context %q, want the plain user name", config.AuthInfos, config.CurrentContext)
+	}
+}
+
+func TestRunSkipApproveForSigner(t *testing.T) {
+	var tests = []struct {
+		name      string
+		skip      []string
+		approvals int
+	}{
+		{name: "approve", skip: []string{"example.com/auto"}, approvals: 1},
+		{name: "skip", skip: []string{"example.com/auto", certificatesv1.KubeAPIServerClientSignerName}, approvals: 0},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.skipApproveSigners = test.skip
+		// the auto-approving signer issues the certificate on create
+		client.PrependReactor("create", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			csr := action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+			csr.Status.Certificate = []byte("certificate")
+			return false, nil, nil
+		})
+
+		if err := o.Run(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		approvals := 0
+		for _, action := range client.Actions() {
+			if action.GetSubresource() == "approval" {
+				approvals++
+			}
+		}
+		if approvals != test.approvals {
+			t.Errorf("%s: approvals: got %d, want %d", test.name, approvals, test.approvals)
+		}
+	}
+}
+
+func TestCompleteRawServer(t *testing.T) {
+	_, caCert, err := cmdutilpkix.CreateSelfSignedCertificate("kubernetes", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := cmdutilpkix.PemCertificate(caCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, ca, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := CertOptions{
+		userName:   "alice",
+		groups:     []string{"dev"},
+		outputMode: defaultOutputMode,
+		rawServer:  "https://10.0.0.1:6443",
+		token:      "token",
+		caFile:     caFile,
+	}
+	if err := o.Complete(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.validateRawServer(); err != nil {
+		t.Fatal(err)
+	}
+
+	clusterName, cluster, err := o.sourceCluster()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clusterName != defaultClusterName || cluster.Server != o.rawServer || !reflect.DeepEqual(cluster.CertificateAuthorityData, ca) {
+		t.Errorf("unexpected cluster %q %+v", clusterName, cluster)
+	}
+
+	o.rawServer = "http://10.0.0.1:8080"
+	if err := o.validateRawServer(); err == nil {
+		t.Error("expected an error for a plain http --raw-server")
+	}
+	o.rawServer, o.merge = "https://10.0.0.1:6443", true
+	if err := o.validateRawServer(); err == nil {
+		t.Error("expected an error for --raw-server with --merge")
+	}
+}
+
+func TestWriteKubeconfigStdout(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	o.output = ""
+	var out bytes.Buffer
+	o.out = &out
+	if err := o.writeKubeconfig([]byte("key"), []byte("certificate")); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := clientcmd.Load(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := config.Contexts["alice@local"]; !ok {
+		t.Errorf("got contexts %v, want alice@local", config.Contexts)
+	}
+}
+
+func TestValidateCurve(t *testing.T) {
+	var tests = []struct {
+		name     string
+		keyType  string
+		curve    string
+		curveSet bool
+		err      bool
+	}{
+		{name: "rsa", keyType: cmdutilpkix.KeyTypeRSA, curve: cmdutilpkix.CurveP256},
+		{name: "rsa with the default curve", keyType: cmdutilpkix.KeyTypeRSA, curve: cmdutilpkix.CurveP256, curveSet: true, err: true},
+		{name: "rsa with a curve", keyType: cmdutilpkix.KeyTypeRSA, curve: cmdutilpkix.CurveP384, curveSet: true, err: true},
+		{name: "ecdsa with a curve", keyType: cmdutilpkix.KeyTypeECDSA, curve: cmdutilpkix.CurveP384, curveSet: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.keyType, o.curve, o.curveSet = test.keyType, test.curve, test.curveSet
+		if err := o.Validate(); test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestCompleteKubeconfigFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.yaml")
+	config := clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"staging": {Server: "https://10.0.0.2:6443"},
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{
+			"admin": {Token: "token"},
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			"admin@staging": {Cluster: "staging", AuthInfo: "admin"},
+		},
+		CurrentContext: "admin@staging",
+	}
+	if err := clientcmd.WriteToFile(config, path); err != nil {
+		t.Fatal(err)
+	}
+
+	o := CertOptions{
+		userName:        "alice",
+		groups:          []string{"dev"},
+		outputMode:      defaultOutputMode,
+		merge:           true,
+		printConfigPath: true,
+	}
+	if err := o.Complete(&genericclioptions.ConfigFlags{KubeConfig: &path}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := o.configPath()
+	if err != nil || got != path {
+		t.Errorf("got config path %q, %v, want %q", got, err, path)
+	}
+	clusterName, _, err := o.sourceCluster()
+	if err != nil || clusterName != "staging" {
+		t.Errorf("got cluster %q, %v, want staging", clusterName, err)
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	var tests = []struct {
+		a, b []string
+		want []string
+	}{
+		{a: []string{"dev", "ops"}, b: []string{"dev", "system:authenticated"}, want: []string{"ops"}},
+		{a: []string{"dev", "system:authenticated"}, b: []string{"dev", "ops"}, want: []string{"system:authenticated"}},
+		{a: []string{"dev"}, b: []string{"dev"}},
+	}
+	for _, test := range tests {
+		if got := subtract(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q - %q: got %q, want %q", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestRunAllowExistingContext(t *testing.T) {
+	_, der, err := cmdutilpkix.CreateSelfSignedCertificate("alice", []string{"dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := cmdutilpkix.PemCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name        string
+		cert        []byte
+		groups      []string
+		renewBefore time.Duration
+		creates     int
+	}{
+		{name: "valid certificate", cert: cert, creates: 0},
+		{name: "no certificate", creates: 1},
+		{name: "other groups", cert: cert, groups: []string{"dev", "ops"}, creates: 1},
+		{name: "due for renewal", cert: testCertificate(t, time.Now().Add(-365*24*time.Hour), 366*24*time.Hour, "dev"), renewBefore: 48 * time.Hour, creates: 1},
+		// valid for less than --renew-before, renewed after two thirds
+		{name: "short-lived", cert: testCertificate(t, time.Now().Add(-time.Hour), 3*time.Hour, "dev"), renewBefore: defaultRenewBefore, creates: 0},
+		{name: "short-lived due", cert: testCertificate(t, time.Now().Add(-150*time.Minute), 3*time.Hour, "dev"), renewBefore: defaultRenewBefore, creates: 1},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.merge = true
+		o.allowExistingContext = true
+		o.renewBefore = test.renewBefore
+		if test.groups != nil {
+			o.groups = test.groups
+		}
+
+		config, err := loadStartingConfig(o.configAccess)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.AuthInfos["alice"] = &clientcmdapi.AuthInfo{ClientCertificateData: test.cert, ClientKeyData: []byte("key")}
+		config.Contexts["alice@local"] = &clientcmdapi.Context{Cluster: "local", AuthInfo: "alice"}
+		if err := clientcmd.ModifyConfig(o.configAccess, *config, false); err != nil {
+			t.Fatal(err)
+		}
+		o.onConflict = onConflictOverwrite
+
+		if err := o.Run(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if n := countActions(client, "create"); n != test.creates {
+			t.Errorf("%s: creates: got %d, want %d", test.name, n, test.creates)
+		}
+	}
+}
+
+func TestRunWatchExisting(t *testing.T) {
+	var tests = []struct {
+		name           string
+		existing       *certificatesv1.CertificateSigningRequest
+		deleteExisting bool
+		failOnExisting bool
+		deletes        int
+		validateErr    bool
+		err            string
+	}{
+		{
+			name:     "issued",
+			existing: &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "alice:dev"}, Status: certificatesv1.CertificateSigningRequestStatus{Certificate: []byte("certificate")}},
+		},
+		{
+			name:           "issued and deleted",
+			existing:       &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "alice:dev"}, Status: certificatesv1.CertificateSigningRequestStatus{Certificate: []byte("certificate")}},
+			deleteExisting: true,
+			deletes:        1,
+		},
+		{name: "missing", err: "nothing to watch"},
+		{name: "fail on existing", failOnExisting: true, validateErr: true},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.watchExisting = true
+		o.deleteExisting = test.deleteExisting
+		o.failOnExisting = test.failOnExisting
+		o.key = []byte("key")
+		if test.existing != nil {
+			if err := client.Tracker().Add(test.existing); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err := o.Validate()
+		if test.validateErr != (err != nil) {
+			t.Errorf("%s: unexpected validate error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		err = o.Run()
+		if len(test.err) != 0 {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if n := countActions(client, "create"); n != 0 {
+			t.Errorf("%s: created %d csrs, want none", test.name, n)
+		}
+		if n := countActions(client, "delete"); n != test.deletes {
+			t.Errorf("%s: got %d deletes, want %d", test.name, n, test.deletes)
+		}
+		config, err := clientcmd.LoadFromFile(o.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(config.AuthInfos["alice"].ClientCertificateData); got != "certificate" {
+			t.Errorf("%s: got certificate %q", test.name, got)
+		}
+	}
+
+	o, _ := newTestCertOptions(t)
+	o.deleteExisting = true
+	if err := o.Validate(); err == nil {
+		t.Errorf("accepted --%s without --%s", flagDeleteExisting, flagWatchExisting)
+	}
+}
+
+func TestRunOffline(t *testing.T) {
+	var tests = []struct {
+		name   string
+		modify func(o *CertOptions)
+		err    bool
+	}{
+		{name: "key and csr"},
+		{name: "no key out", modify: func(o *CertOptions) { o.keyOut = "" }, err: true},
+		{name: "watch existing", modify: func(o *CertOptions) { o.watchExisting = true }, err: true},
+		{name: "cert out", modify: func(o *CertOptions) { o.certOut = filepath.Join(t.TempDir(), "alice.crt") }, err: true},
+		{name: "dry run", modify: func(o *CertOptions) { o.dryRun = dryRunClient }, err: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		// offline needs no client
+		o.clientSet = nil
+		o.offline = true
+		o.keyOut = filepath.Join(t.TempDir(), "alice.key")
+		if test.modify != nil {
+			test.modify(o)
+		}
+
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		if err := o.Run(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		request, err := os.ReadFile(o.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		csr, err := cmdutilpkix.ParseCertificateRequestPem(request)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if csr.Subject.CommonName != "alice" || !reflect.DeepEqual(csr.Subject.Organization, []string{"dev"}) {
+			t.Errorf("%s: got subject %s", test.name, csr.Subject)
+		}
+		if _, err := os.Stat(o.keyOut); err != nil {
+			t.Errorf("%s: key not written: %v", test.name, err)
+		}
+	}
+}
+
+func TestRunRequestOnly(t *testing.T) {
+	var tests = []struct {
+		name   string
+		modify func(o *CertOptions)
+		err    bool
+	}{
+		{name: "base64 request"},
+		{name: "no key out", modify: func(o *CertOptions) { o.keyOut = "" }, err: true},
+		{name: "offline", modify: func(o *CertOptions) { o.offline = true }, err: true},
+		{name: "request from", modify: func(o *CertOptions) { o.requestFrom = "alice.csr" }, err: true},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.requestOnly = true
+		o.keyOut = filepath.Join(t.TempDir(), "alice.key")
+		if test.modify != nil {
+			test.modify(o)
+		}
+
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		if err := o.Run(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if n := len(client.Actions()); n != 0 {
+			t.Errorf("%s: got %d api calls, want no csr created", test.name, n)
+		}
+		out, err := os.ReadFile(o.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+		if err != nil {
+			t.Fatalf("%s: output is not base64: %v", test.name, err)
+		}
+		csr, err := cmdutilpkix.ParseCertificateRequestPem(request)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if csr.Subject.CommonName != "alice" {
+			t.Errorf("%s: got subject %s", test.name, csr.Subject)
+		}
+	}
+}
+
+func TestRunOfflineKeyFormat(t *testing.T) {
+	var tests = []struct {
+		keyType   string
+		keyFormat string
+		blockType string
+		err       bool
+	}{
+		{keyType: cmdutilpkix.KeyTypeRSA, keyFormat: cmdutilpkix.KeyFormatPKCS8, blockType: "PRIVATE KEY"},
+		{keyType: cmdutilpkix.KeyTypeRSA, keyFormat: cmdutilpkix.KeyFormatPKCS1, blockType: "RSA PRIVATE KEY"},
+		{keyType: cmdutilpkix.KeyTypeECDSA, keyFormat: cmdutilpkix.KeyFormatSEC1, blockType: "EC PRIVATE KEY"},
+		{keyType: cmdutilpkix.KeyTypeECDSA, keyFormat: cmdutilpkix.KeyFormatPKCS1, err: true},
+		{keyType: cmdutilpkix.KeyTypeRSA, keyFormat: "der", err: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.offline = true
+		o.keyOut = filepath.Join(t.TempDir(), "alice.key")
+		o.keyType = test.keyType
+		o.keyFormat = test.keyFormat
+
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%s %s: unexpected error %v", test.keyType, test.keyFormat, err)
+		}
+		if err != nil {
+			continue
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := os.ReadFile(o.keyOut)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(key), "-----BEGIN "+test.blockType+"-----") {
+			t.Errorf("%s %s: unexpected key %.40q", test.keyType, test.keyFormat, key)
+		}
+	}
+}
+
+func TestWriteKubeconfigSerialOut(t *testing.T) {
+	_, der, err := cmdutilpkix.CreateSelfSignedCertificate("alice", []string{"dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := cmdutilpkix.PemCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, _ := newTestCertOptions(t)
+	o.serialOut = filepath.Join(t.TempDir(), "alice.serial")
+	if err := o.writeKubeconfig([]byte("key"), cert); err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := os.ReadFile(o.serialOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// CreateSelfSignedCertificate uses serial number 2021
+	if string(serial) != "7e5\n" {
+		t.Errorf("got %q, want %q", serial, "7e5\n")
+	}
+}
+
+func TestSystemIdentities(t *testing.T) {
+	var tests = []struct {
+		userName string
+		groups   []string
+		want     []string
+	}{
+		{userName: "alice", groups: []string{"dev"}},
+		{userName: "alice", groups: []string{"dev", "system:masters"}, want: []string{"system:masters"}},
+		{userName: "system:node:worker-1", groups: []string{"system:nodes"}, want: []string{"system:node:worker-1", "system:nodes"}},
+		{userName: "systemd", groups: []string{"ops:system:dev"}},
+	}
+	for _, test := range tests {
+		if got := systemIdentities(test.userName, test.groups); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q %q: got %q, want %q", test.userName, test.groups, got, test.want)
+		}
+	}
+
+	o, _ := newTestCertOptions(t)
+	o.groups = []string{"system:masters"}
+	if err := o.Validate(); err == nil {
+		t.Error("expected an error for system:masters without --allow-system-identities")
+	}
+	o.allowSystemIdentities = true
+	if err := o.Validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	var tests = []struct {
+		name       string
+		expiration time.Duration
+		max        time.Duration
+		clamp      bool
+		want       int32
+		err        bool
+	}{
+		{name: "signer default"},
+		{name: "max without expiration", max: 24 * time.Hour, want: 86400},
+		{name: "within limit", expiration: time.Hour, max: 24 * time.Hour, want: 3600},
+		{name: "above limit", expiration: 48 * time.Hour, max: 24 * time.Hour, err: true},
+		{name: "clamped", expiration: 48 * time.Hour, max: 24 * time.Hour, clamp: true, want: 86400},
+		{name: "too short", expiration: time.Minute, err: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.expiration, o.maxExpiration, o.clampExpiration = test.expiration, test.max, test.clamp
+
+		o.clampToMaxExpiration()
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		csr, err := o.createCertificatesV1CertificateSigningRequest([]byte("request"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got int32
+		if csr.Spec.ExpirationSeconds != nil {
+			got = *csr.Spec.ExpirationSeconds
+		}
+		if got != test.want {
+			t.Errorf("%s: expiration seconds: got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSecretManifest(t *testing.T) {
+	content := []byte("apiVersion: v1\nkind: Config\n")
+
+	manifest, err := secretManifest("alice-kubeconfig", "tenants", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secret corev1.Secret
+	if err := yaml.UnmarshalStrict(manifest, &secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret.Kind != "Secret" || secret.Name != "alice-kubeconfig" || secret.Namespace != "tenants" {
+		t.Errorf("got %s %s/%s, want Secret tenants/alice-kubeconfig", secret.Kind, secret.Namespace, secret.Name)
+	}
+	if got := string(secret.Data[secretKeyKubeconfig]); got != string(content) {
+		t.Errorf("got %q, want %q", got, content)
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	var tests = []struct {
+		name      string
+		format    string
+		secret    string
+		namespace string
+		merge     bool
+		err       bool
+	}{
+		{name: "kubeconfig", format: formatKubeconfig},
+		{name: "kubeconfig with secret name", format: formatKubeconfig, secret: "alice", err: true},
+		{name: "secret", format: formatSecret, secret: "alice", namespace: "tenants"},
+		{name: "secret without namespace", format: formatSecret, secret: "alice.kubeconfig"},
+		{name: "secret without name", format: formatSecret, err: true},
+		{name: "invalid name", format: formatSecret, secret: "Alice_", err: true},
+		{name: "invalid namespace", format: formatSecret, secret: "alice", namespace: "a.b", err: true},
+		{name: "secret with merge", format: formatSecret, secret: "alice", merge: true, err: true},
+		{name: "unknown", format: "json", err: true},
+	}
+	for _, test := range tests {
+		o := CertOptions{format: test.format, secretName: test.secret, secretNamespace: test.namespace, merge: test.merge}
+		err := o.validateFormat()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestCompleteStdinKubeconfig(t *testing.T) {
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: local
+  cluster:
+    server: https://10.0.0.1:6443
+users:
+- name: admin
+  user:
+    token: token
+contexts:
+- name: admin@local
+  context:
+    cluster: local
+    user: admin
+current-context: admin@local
+`
+
+	var tests = []struct {
+		name        string
+		stdin       string
+		requestFrom string
+		err         bool
+	}{
+		{name: "kubeconfig", stdin: kubeconfig},
+		{name: "empty", stdin: "", err: true},
+		{name: "two documents", stdin: kubeconfig + "---\n" + kubeconfig, err: true},
+		{name: "invalid", stdin: "apiVersion: v1\nkind: Config\ncurrent-context: missing\n", err: true},
+		{name: "request from stdin", stdin: kubeconfig, requestFrom: "-", err: true},
+	}
+	for _, test := range tests {
+		path := "-"
+		o, _ := newTestCertOptions(t)
+		o.outputMode = defaultOutputMode
+		o.requestFrom = test.requestFrom
+		o.in = strings.NewReader(test.stdin)
+		err := o.Complete(&genericclioptions.ConfigFlags{KubeConfig: &path})
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+
+		if o.restConfig.Host != "https://10.0.0.1:6443" || o.restConfig.BearerToken != "token" {
+			t.Errorf("%s: unexpected rest config %+v", test.name, o.restConfig)
+		}
+		clusterName, _, err := o.sourceCluster()
+		if err != nil || clusterName != "local" {
+			t.Errorf("%s: got cluster %q, %v, want local", test.name, clusterName, err)
+		}
+		o.merge = true
+		if err := o.Validate(); err == nil || !strings.Contains(err.Error(), flagStdinKubeconfig) {
+			t.Errorf("%s: got %v, want an error for --%s with --%s", test.name, err, flagStdinKubeconfig, flagMerge)
+		}
+	}
+}
+
+func TestRunApproveMessageTemplate(t *testing.T) {
+	var tests = []struct {
+		template string
+		want     string
+		err      bool
+	}{
+		{template: "", want: defaultApproveMessage},
+		{template: "{{.Reason}} by {{.Operator}} for {{.User}} in {{join .Groups \",\"}}", err: true},
+		{template: "{{.Reason}} by {{.Operator}} for {{.User}} {{.Groups}}", want: ReasonKconfigCertApprove + " by admin for alice [dev]"},
+		{template: "{{.Nope}}", err: true},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.approveMsgTemplate = test.template
+
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%q: unexpected error %v", test.template, err)
+		}
+		if err != nil {
+			continue
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, action := range client.Actions() {
+			if action.GetSubresource() != "approval" {
+				continue
+			}
+			csr := action.(k8stesting.UpdateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+			if got := csr.Status.Conditions[0].Message; got != test.want {
+				t.Errorf("%q: got %q, want %q", test.template, got, test.want)
+			}
+		}
+	}
+}
+
+func TestRunMergeInto(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	o.merge = true
+	o.output = ""
+	o.mergeInto = filepath.Join(t.TempDir(), "users.config")
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := clientcmd.LoadFromFile(o.mergeInto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := merged.Contexts["alice@local"]; !ok {
+		t.Errorf("context alice@local not merged into --%s, got %v", flagMergeInto, merged.Contexts)
+	}
+	if fi, err := os.Stat(o.mergeInto); err != nil || fi.Mode().Perm() != keyFileMode {
+		t.Errorf("mode of --%s: %v %v", flagMergeInto, fi.Mode(), err)
+	}
+	source, err := loadStartingConfig(o.configAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := source.AuthInfos["alice"]; ok {
+		t.Error("source kubeconfig modified")
+	}
+
+	// the new key conflicts with the user merged before
+	if err := o.Run(); err == nil {
+		t.Errorf("expected a conflict in --%s", flagMergeInto)
+	}
+}
+
+func TestRunRequestJSON(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.format = formatRequestJSON
+	o.keyOut = filepath.Join(t.TempDir(), "alice.key")
+	o.expiration = time.Hour
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(client.Actions()); n != 0 {
+		t.Errorf("got %d actions, want none", n)
+	}
+
+	content, err := os.ReadFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec struct {
+		Request           []byte   `json:"request"`
+		Username          string   `json:"username"`
+		Groups            []string `json:"groups"`
+		Usages            []string `json:"usages"`
+		SignerName        string   `json:"signerName"`
+		ExpirationSeconds int32    `json:"expirationSeconds"`
+	}
+	if err := json.Unmarshal(content, &spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.Username != "alice" || !reflect.DeepEqual(spec.Groups, []string{"dev"}) || spec.SignerName != certificatesv1.KubeAPIServerClientSignerName || spec.ExpirationSeconds != 3600 {
+		t.Errorf("unexpected spec %+v", spec)
+	}
+	if !reflect.DeepEqual(spec.Usages, []string{string(certificatesv1.UsageClientAuth)}) {
+		t.Errorf("usages: got %q", spec.Usages)
+	}
+	key, err := os.ReadFile(o.keyOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdutilpkix.VerifyCertificateRequestPem(key, spec.Request); err != nil {
+		t.Errorf("request does not match --%s: %v", flagKeyOut, err)
+	}
+
+	o.keyOut = ""
+	if err := o.Validate(); err == nil {
+		t.Errorf("expected an error without --%s", flagKeyOut)
+	}
+}
+
+func TestRunPrintSubject(t *testing.T) {
+	var tests = []struct {
+		dryRun string
+		submit bool
+	}{
+		{dryRun: dryRunClient},
+		{dryRun: dryRunNone, submit: true},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.groups = []string{"dev", "ops"}
+		o.printSubject = true
+		o.dryRun = test.dryRun
+		var errOut strings.Builder
+		o.errOut = &errOut
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := errOut.String(), "Subject: CN=alice,O=dev+O=ops\n"; !strings.HasPrefix(got, want) {
+			t.Errorf("%s: got %q, want prefix %q", test.dryRun, got, want)
+		}
+		if submitted := countActions(client, "create") != 0; submitted != test.submit {
+			t.Errorf("%s: got submitted %t, want %t", test.dryRun, submitted, test.submit)
+		}
+	}
+}
+
+func TestRunSubjectGroup(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.subjectGroups = []string{"dev", "signer:ops"}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var created *certificatesv1.CertificateSigningRequest
+	for _, action := range client.Actions() {
+		if action.Matches("create", "certificatesigningrequests") {
+			created = action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		}
+	}
+	if created == nil {
+		t.Fatal("no csr created")
+	}
+	if want := []string{"dev"}; !reflect.DeepEqual(created.Spec.Groups, want) {
+		t.Errorf("spec groups: got %q, want %q", created.Spec.Groups, want)
+	}
+	csr, err := cmdutilpkix.ParseCertificateRequestPem(created.Spec.Request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dev", "signer:ops"}; !reflect.DeepEqual(csr.Subject.Organization, want) {
+		t.Errorf("subject organizations: got %q, want %q", csr.Subject.Organization, want)
+	}
+
+	o.subjectGroups = []string{"system:masters"}
+	if err := o.Validate(); err == nil {
+		t.Error("allowed a reserved subject group")
+	}
+}
+
+func TestRunRequestProfile(t *testing.T) {
+	var tests = []struct {
+		profile string
+		err     bool
+	}{
+		{profile: ""},
+		{profile: "short-lived.team-a"},
+		{profile: "Team A", err: true},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.requestProfile = test.profile
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%q: unexpected error %v", test.profile, err)
+		}
+		if err != nil {
+			continue
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, action := range client.Actions() {
+			if !action.Matches("create", "certificatesigningrequests") {
+				continue
+			}
+			csr := action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+			if got, ok := csr.Annotations[annotationRequestProfile]; got != test.profile || ok != (len(test.profile) != 0) {
+				t.Errorf("%q: got annotation %q", test.profile, got)
+			}
+		}
+	}
+}
+
+func TestRunDeleteOnSuccess(t *testing.T) {
+	var tests = []struct {
+		name            string
+		deleteOnSuccess bool
+		set             bool
+		deletes         int
+		notice          bool
+	}{
+		{name: "default", deleteOnSuccess: true, deletes: 1, notice: true},
+		{name: "delete", deleteOnSuccess: true, set: true, deletes: 1},
+		{name: "keep", set: true},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.deleteOnSuccess, o.deleteOnSuccessSet = test.deleteOnSuccess, test.set
+		var errOut strings.Builder
+		o.errOut = &errOut
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := countActions(client, "delete"); n != test.deletes {
+			t.Errorf("%s: got %d deletes, want %d", test.name, n, test.deletes)
+		}
+		if notice := strings.Contains(errOut.String(), "NOTICE"); notice != test.notice {
+			t.Errorf("%s: got notice %t, want %t: %q", test.name, notice, test.notice, errOut.String())
+		}
+	}
+}
+
+func TestRunDeleteOnSuccessNoticeOnce(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".kconfig", "config.yaml")
+
+	var tests = []struct {
+		name       string
+		configFile string
+		runs       int
+		notices    int
+	}{
+		{name: "renewal cycles", runs: 2, notices: 1},
+		{name: "first run", configFile: configFile, runs: 1, notices: 1},
+		{name: "later run", configFile: configFile, runs: 1, notices: 0},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.deleteOnSuccessSet = false
+		o.configFile = test.configFile
+		var errOut strings.Builder
+		o.errOut = &errOut
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		for run := 0; run < test.runs; run++ {
+			if err := o.Run(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if n := strings.Count(errOut.String(), "NOTICE"); n != test.notices {
+			t.Errorf("%s: got %d notices, want %d", test.name, n, test.notices)
+		}
+	}
+}
+
+func TestRunRecordOperator(t *testing.T) {
+	_, der, err := cmdutilpkix.CreateSelfSignedCertificate("kubernetes-admin", []string{"system:masters"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := cmdutilpkix.PemCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name       string
+		record     bool
+		restConfig *rest.Config
+		want       string
+	}{
+		{name: "client certificate", record: true, restConfig: &rest.Config{TLSClientConfig: rest.TLSClientConfig{CertData: cert}}, want: "kubernetes-admin"},
+		{name: "basic auth", record: true, restConfig: &rest.Config{Username: "bob"}, want: "bob"},
+		{name: "token", record: true, restConfig: &rest.Config{BearerToken: "token"}},
+		{name: "disabled", restConfig: &rest.Config{TLSClientConfig: rest.TLSClientConfig{CertData: cert}}},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.recordOperator = test.record
+		o.restConfig = test.restConfig
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, action := range client.Actions() {
+			if !action.Matches("create", "certificatesigningrequests") {
+				continue
+			}
+			csr := action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+			if got, ok := csr.Annotations[annotationIssuedBy]; got != test.want || ok != (len(test.want) != 0) {
+				t.Errorf("%s: got issued-by %q, want %q", test.name, got, test.want)
+			}
+		}
+	}
+}
+
+func TestRunDescription(t *testing.T) {
+	configAccess := newTestConfigAccess(t)
+	for _, user := range []struct {
+		name        string
+		description string
+	}{
+		{name: "alice", description: "ci deploys"},
+		{name: "bob", description: "on-call access"},
+	} {
+		o, _ := newTestCertOptions(t)
+		o.configAccess = configAccess
+		o.userName = user.name
+		o.csrName = user.name + ":dev"
+		o.description = user.description
+		o.merge = true
+		o.output = ""
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// alice's extension survives merging bob
+	config, err := loadStartingConfig(configAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"alice@local": "ci deploys", "bob@local": "on-call access", "admin@local": ""} {
+		ctx, ok := config.Contexts[name]
+		if !ok {
+			t.Fatalf("context %q not merged", name)
+		}
+		if got := contextDescription(ctx); got != want {
+			t.Errorf("%s: got description %q, want %q", name, got, want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := (&ContextsOptions{configAccess: configAccess, out: &out}).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "on-call access") {
+		t.Errorf("description not listed in %q", out.String())
+	}
+}
+
+func TestRunContexts(t *testing.T) {
+	var tests = []struct {
+		name   string
+		config string
+		want   []string
+		err    bool
+	}{
+		{
+			name: "contexts",
+			config: `apiVersion: v1
+kind: Config
+clusters:
+- name: local
+  cluster: {server: "https://127.0.0.1:6443"}
+users:
+- name: admin
+  user: {token: token}
+contexts:
+- name: dev@local
+  context: {cluster: local, user: admin, namespace: dev}
+- name: admin@local
+  context: {cluster: local, user: admin}
+current-context: dev@local
+`,
+			want: []string{
+				"CURRENT   NAME          CLUSTER   AUTHINFO   NAMESPACE   DESCRIPTION",
+				"          admin@local   local     admin                  ",
+				"*         dev@local     local     admin      dev         ",
+			},
+		},
+		{name: "malformed", config: "contexts: [", err: true},
+	}
+	for _, test := range tests {
+		// a kubeconfig other than the default, read through --kubeconfig
+		path := filepath.Join(t.TempDir(), "a.yaml")
+		if err := os.WriteFile(path, []byte(test.config), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		o := &ContextsOptions{out: &out}
+		if err := o.Complete(&genericclioptions.ConfigFlags{KubeConfig: &path}); err != nil {
+			t.Fatal(err)
+		}
+		err := o.Run()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestRunNoCurrentContext(t *testing.T) {
+	var tests = []struct {
+		noCurrentContext bool
+		want             string
+	}{
+		{want: "alice@local"},
+		{noCurrentContext: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.noCurrentContext = test.noCurrentContext
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		config, err := clientcmd.LoadFromFile(o.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.CurrentContext != test.want {
+			t.Errorf("%t: got current context %q, want %q", test.noCurrentContext, config.CurrentContext, test.want)
+		}
+		if _, ok := config.Contexts["alice@local"]; !ok {
+			t.Errorf("%t: context alice@local not written", test.noCurrentContext)
+		}
+	}
+}
+
+// testCABundle returns a root and an intermediate certificate as one pem
+// bundle.
+func testCABundle(t *testing.T) []byte {
+	t.Helper()
+
+	var bundle []byte
+	for _, cn := range []string{"root-ca", "intermediate-ca"} {
+		_, der, err := cmdutilpkix.CreateSelfSignedCertificate(cn, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := cmdutilpkix.PemCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle = append(bundle, cert...)
+	}
+	return bundle
+}
+
+func TestRunCABundle(t *testing.T) {
+	bundle := testCABundle(t)
+
+	o, _ := newTestCertOptions(t)
+	config, err := o.configAccess.GetStartingConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Clusters["local"].CertificateAuthorityData = bundle
+	if err := clientcmd.ModifyConfig(o.configAccess, *config, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := written.Clusters["local"].CertificateAuthorityData
+	if !bytes.Equal(ca, bundle) {
+		t.Errorf("got certificate authority %q, want the bundle %q", ca, bundle)
+	}
+	if certs, err := cmdutilpkix.ParseCertificatesPem(ca); err != nil || len(certs) != 2 {
+		t.Errorf("got %d certificate authorities, %v, want 2", len(certs), err)
+	}
+}
+
+func TestRestConfigClusterCABundle(t *testing.T) {
+	bundle := testCABundle(t)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := os.WriteFile(caFile, bundle, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, err := restConfigCluster(&rest.Config{Host: "https://127.0.0.1:6443", TLSClientConfig: rest.TLSClientConfig{CAFile: caFile}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cluster.CertificateAuthorityData, bundle) {
+		t.Errorf("got certificate authority %q, want the bundle %q", cluster.CertificateAuthorityData, bundle)
+	}
+}
+
+func TestValidateKubeconfig(t *testing.T) {
+	var tests = []struct {
+		name    string
+		modify  func(*clientcmdapi.Config)
+		keyless bool
+		err     bool
+	}{
+		{name: "valid", modify: func(*clientcmdapi.Config) {}},
+		{name: "no server", modify: func(c *clientcmdapi.Config) { c.Clusters["local"].Server = "" }, err: true},
+		{name: "dangling cluster", modify: func(c *clientcmdapi.Config) { c.Contexts["alice@local"].Cluster = "remote" }, err: true},
+		{name: "dangling current context", modify: func(c *clientcmdapi.Config) { c.CurrentContext = "bob@local" }, err: true},
+		{name: "no key", modify: func(c *clientcmdapi.Config) { c.AuthInfos["alice"].ClientKeyData = nil }, err: true},
+		{name: "keyless", modify: func(c *clientcmdapi.Config) { c.AuthInfos["alice"].ClientKeyData = nil }, keyless: true},
+	}
+	for _, test := range tests {
+		kubeconfig := newKubeconfig("local", &clientcmdapi.Cluster{Server: "https://127.0.0.1:6443"}, "alice", "", []byte("key"), []byte("certificate"))
+		test.modify(&kubeconfig)
+		err := validateKubeconfig(kubeconfig, test.keyless)
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestValidateInvalidKubeconfig(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	config, err := o.configAccess.GetStartingConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Clusters["local"].Server = ""
+	if err := clientcmd.ModifyConfig(o.configAccess, *config, false); err != nil {
+		t.Fatal(err)
+	}
+	err = o.Validate()
+	if err == nil || !strings.Contains(err.Error(), "invalid kubeconfig") {
+		t.Errorf("got error %v, want an invalid kubeconfig", err)
+	}
+	if n := len(client.Actions()); n != 0 {
+		t.Errorf("got %d api calls, want none", n)
+	}
+}
+
+func TestRunDeleteOnPanic(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	client.PrependReactor("update", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		panic("approval")
+	})
+
+	defer func() {
+		if r := recover(); r != "approval" {
+			t.Fatalf("got panic %v, want approval", r)
+		}
+		if n := countActions(client, "delete"); n != 1 {
+			t.Errorf("deletes: got %d, want 1", n)
+		}
+		if _, err := o.getCertificateSigningRequest(); !apierrors.IsNotFound(err) {
+			t.Errorf("csr not deleted after panic: %v", err)
+		}
+	}()
+	o.Run()
+}
+
+func TestRunFailOnExisting(t *testing.T) {
+	created := metav1.NewTime(time.Date(2022, 2, 14, 9, 38, 31, 0, time.UTC))
+	var tests = []struct {
+		failOnExisting bool
+		err            bool
+	}{
+		{failOnExisting: false},
+		{failOnExisting: true, err: true},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+		o.failOnExisting = test.failOnExisting
+		existing := &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              o.csrName,
+				CreationTimestamp: created,
+				Annotations:       map[string]string{annotationCreator: "ci.example.com"},
+			},
+		}
+		if err := client.Tracker().Add(existing); err != nil {
+			t.Fatal(err)
+		}
+
+		err := o.Run()
+		if test.err != (err != nil) {
+			t.Fatalf("fail on existing %t: unexpected error %v", test.failOnExisting, err)
+		}
+		if err != nil {
+			for _, want := range []string{"2022-02-14T09:38:31Z", "ci.example.com"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+			if n := countActions(client, "delete"); n != 0 {
+				t.Errorf("deletes: got %d, want 0", n)
+			}
+		}
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	var tests = []struct {
+		name          string
+		groups        []string
+		roles         []string
+		allowNoGroups bool
+		err           bool
+	}{
+		{name: "groups", groups: []string{"dev"}},
+		{name: "none", err: true},
+		{name: "unknown roles only", roles: []string{"admin"}, err: true},
+		{name: "none allowed", allowNoGroups: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.groups, o.roles, o.allowNoGroups = test.groups, test.roles, test.allowNoGroups
+		err := o.Validate()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
+
+func TestRunOutputOwner(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	o.keyOut = filepath.Join(t.TempDir(), "alice.key")
+	o.outputOwner, o.outputGroup = strconv.Itoa(os.Geteuid()), strconv.Itoa(os.Getegid())
+	o.outputUID, o.outputGID = os.Geteuid(), os.Getegid()
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	o.outputUID = 1<<31 - 1
+	if os.Geteuid() != 0 {
+		if err := o.Validate(); err == nil {
+			t.Errorf("expected an error for --%s without permission", flagOutputOwner)
+		}
+	}
+}
+
+func TestRunOutputOwnerAfterAudit(t *testing.T) {
+	defer func(f func(string, int, int) error) { chown = f }(chown)
+	owners := map[string][2]int{}
+	chown = func(name string, uid, gid int) error {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("chown of %s before it was written: %v", name, err)
+		}
+		owners[name] = [2]int{uid, gid}
+		return nil
+	}
+
+	dir := t.TempDir()
+	o, _ := newTestCertOptions(t)
+	o.keyOut = filepath.Join(dir, "alice.key")
+	o.auditOut = filepath.Join(dir, "audit.json")
+	o.outputOwner, o.outputGroup = "1234", "5678"
+	o.outputUID, o.outputGID = 1234, 5678
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{o.output, o.keyOut, o.auditOut} {
+		if got := owners[name]; got != [2]int{1234, 5678} {
+			t.Errorf("%s: got owner %v, want 1234:5678", name, got)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package cert
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/klog/v2"
+)
+
+const (
+	flagBackend          = "backend"
+	flagIssuerName       = "issuer-name"
+	flagIssuerKind       = "issuer-kind"
+	flagRequestNamespace = "request-namespace"
+
+	backendCSR         = "csr"
+	backendCertManager = "certmanager"
+
+	issuerKindIssuer        = "Issuer"
+	issuerKindClusterIssuer = "ClusterIssuer"
+
+	certManagerGroup = "cert-manager.io"
+)
+
+var (
+	certificateRequestResource = schema.GroupVersionResource{Group: certManagerGroup, Version: "v1", Resource: "certificaterequests"}
+
+	certificateRequestPollInterval = 2 * time.Second
+)
+
+// certManagerIssuer uses a namespaced cert-manager.io/v1 CertificateRequest,
+// which cert-manager approves and signs by the referenced issuer.
+type certManagerIssuer struct {
+	o *CertOptions
+}
+
+func (i certManagerIssuer) issue() ([]byte, []byte, error) {
+	o := i.o
+
+	if o.failOnExisting {
+		existing, err := i.client().Get(context.TODO(), i.name(), metav1.GetOptions{})
+		if err == nil {
+			return nil, nil, existingError("certificaterequest", existing)
+		}
+		if !apierrors.IsNotFound(err) {
+			return nil, nil, err
+		}
+	}
+	err := i.delete()
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, nil, err
+	}
+
+	key, request := o.key, o.request
+	if len(o.requestFrom) == 0 {
+		key, request, err = o.createCertificateRequest()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	klog.V(2).InfoS("create certificaterequest", "certificaterequest", i.name(), "namespace", o.requestNamespace, "phase", "create", "duration", time.Since(o.start))
+	_, err = i.client().Create(context.TODO(), i.certificateRequest(request), metav1.CreateOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := i.waitForCertificate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+func (i certManagerIssuer) delete() error {
+	klog.V(2).InfoS("delete certificaterequest", "certificaterequest", i.name(), "namespace", i.o.requestNamespace, "phase", "delete", "duration", time.Since(i.o.start))
+	return i.client().Delete(context.TODO(), i.name(), metav1.DeleteOptions{})
+}
+
+func (i certManagerIssuer) client() dynamic.ResourceInterface {
+	return i.o.dynamicClient.Resource(certificateRequestResource).Namespace(i.o.requestNamespace)
+}
+
+func (i certManagerIssuer) name() string {
+	return certificateRequestName(i.o.csrName)
+}
+
+func (i certManagerIssuer) certificateRequest(request []byte) *unstructured.Unstructured {
+	o := i.o
+
+	spec := map[string]interface{}{
+		"request": base64.StdEncoding.EncodeToString(request),
+		"issuerRef": map[string]interface{}{
+			"name":  o.issuerName,
+			"kind":  o.issuerKind,
+			"group": certManagerGroup,
+		},
+		"usages": []interface{}{"digital signature", "key encipherment", "client auth"},
+	}
+	if o.expiration > 0 {
+		spec["duration"] = o.expiration.String()
+	}
+
+	cr := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": certificateRequestResource.GroupVersion().String(),
+			"kind":       "CertificateRequest",
+			"spec":       spec,
+		},
+	}
+	// labeled and annotated like the csr of the csr backend
+	csr := o.certificateSigningRequest(request)
+	cr.SetName(i.name())
+	cr.SetNamespace(o.requestNamespace)
+	cr.SetLabels(csr.Labels)
+	cr.SetAnnotations(csr.Annotations)
+	return cr
+}
+
+// waitForCertificate polls the CertificateRequest until cert-manager
+// populates its certificate, failing once it is denied or failed or the
+// timeout expires.
+func (i certManagerIssuer) waitForCertificate() ([]byte, error) {
+	o := i.o
+	klog.V(2).InfoS("wait for certificaterequest to be issued", "certificaterequest", i.name(), "namespace", o.requestNamespace, "phase", "wait", "duration", time.Since(o.start))
+	defer o.startProgress(fmt.Sprintf("waiting for certificaterequest %s/%s to be issued", o.requestNamespace, i.name()))()
+
+	ctx := context.Background()
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	var cert []byte
+	err := wait.PollImmediateUntil(certificateRequestPollInterval, func() (bool, error) {
+		cr, err := i.client().Get(ctx, i.name(), metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		cert, err = certificateRequestIssued(cr)
+		return len(cert) != 0, err
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		return nil, fmt.Errorf("timed out after %s waiting for certificaterequest %s/%s to be issued", o.timeout, o.requestNamespace, i.name())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	klog.V(2).InfoS("certificaterequest issued", "certificaterequest", i.name(), "namespace", o.requestNamespace, "phase", "issued", "duration", time.Since(o.start))
+	return cert, nil
+}
+
+// certificateRequestIssued returns the certificate of the CertificateRequest
+// once issued and fails for denied, invalid or failed requests.
+func certificateRequestIssued(cr *unstructured.Unstructured) ([]byte, error) {
+	conditions, _, err := unstructured.NestedSlice(cr.Object, "status", "conditions")
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		typ, status, reason := condition["type"], condition["status"], condition["reason"]
+		switch {
+		case typ == "Denied" && status == string(metav1.ConditionTrue):
+			return nil, fmt.Errorf("%w: certificaterequest %q, reason %q: %s", errCertificateDenied, cr.GetName(), reason, condition["message"])
+		case typ == "InvalidRequest" && status == string(metav1.ConditionTrue),
+			typ == "Ready" && status == string(metav1.ConditionFalse) && reason == "Failed":
+			return nil, fmt.Errorf("certificaterequest %q failed, reason %q: %s", cr.GetName(), reason, condition["message"])
+		}
+	}
+
+	encoded, _, err := unstructured.NestedString(cr.Object, "status", "certificate")
+	if err != nil || len(encoded) == 0 {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// certificateRequestName turns the csr name, which may hold colons and
+// upper case letters, into a valid object name.
+func certificateRequestName(csrName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, csrName)
+	if len(validation.IsDNS1123Subdomain(name)) == 0 {
+		return name
+	}
+	sum := sha256.Sum256([]byte(csrName))
+	return fmt.Sprintf("kconfig-%x", sum[:8])
+}
+
+func (o *CertOptions) validateBackend() error {
+	switch o.backend {
+	case backendCSR:
+		if len(o.issuerName) != 0 {
+			return fmt.Errorf("--%s requires --%s=%s", flagIssuerName, flagBackend, backendCertManager)
+		}
+		return nil
+	case backendCertManager:
+	default:
+		return fmt.Errorf("--%s must be '%s' or '%s'", flagBackend, backendCSR, backendCertManager)
+	}
+
+	if len(o.issuerName) == 0 {
+		return fmt.Errorf("--%s=%s requires --%s", flagBackend, backendCertManager, flagIssuerName)
+	}
+	if o.issuerKind != issuerKindIssuer && o.issuerKind != issuerKindClusterIssuer {
+		return fmt.Errorf("--%s must be '%s' or '%s'", flagIssuerKind, issuerKindIssuer, issuerKindClusterIssuer)
+	}
+	if errs := validation.IsDNS1123Label(o.requestNamespace); len(errs) != 0 {
+		return fmt.Errorf("invalid --%s %q: %s", flagRequestNamespace, o.requestNamespace, strings.Join(errs, ", "))
+	}
+	if o.watchExisting || o.dryRun != dryRunNone || o.confirmApprove {
+		return fmt.Errorf("--%s=%s cannot be used with --%s, --%s or --%s", flagBackend, backendCertManager, flagWatchExisting, flagDryRun, flagConfirmApprove)
+	}
+	// the issuer signs and the request carries fixed usages, there is no
+	// csr signer, approval or denial
+	if o.deniedRetries != 0 || o.forceRecreateOnDenied || len(o.skipApproveSigners) != 0 {
+		return fmt.Errorf("--%s=%s cannot be used with --%s, --%s or --%s", flagBackend, backendCertManager, flagDeniedRetries, flagForceRecreateOnDenied, flagSkipApproveSigner)
+	}
+	if o.usagesSet || o.signerNameSet {
+		return fmt.Errorf("--%s=%s cannot be used with --%s or --%s, the issuer decides", flagBackend, backendCertManager, flagUsages, flagSignerName)
+	}
+	return nil
+}
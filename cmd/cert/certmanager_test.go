@@ -0,0 +1,152 @@
+package cert
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestRunCertManager(t *testing.T) {
+	client := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme())
+	var created *unstructured.Unstructured
+	client.PrependReactor("create", "certificaterequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		created = action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		return false, nil, nil
+	})
+	client.PrependReactor("get", "certificaterequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if created == nil {
+			return false, nil, nil
+		}
+		cr := created.DeepCopy()
+		unstructured.SetNestedField(cr.Object, base64.StdEncoding.EncodeToString([]byte("certificate")), "status", "certificate")
+		return true, cr, nil
+	})
+
+	o, csrClient := newTestCertOptions(t)
+	o.backend = backendCertManager
+	o.issuerName = "users"
+	o.issuerKind = issuerKindClusterIssuer
+	o.requestNamespace = "pki"
+	o.dynamicClient = client
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if created == nil || created.GetNamespace() != "pki" || created.GetName() != "alice-dev" {
+		t.Fatalf("got certificaterequest %v, want pki/alice-dev", created)
+	}
+	kind, _, _ := unstructured.NestedString(created.Object, "spec", "issuerRef", "kind")
+	if kind != issuerKindClusterIssuer {
+		t.Errorf("issuer kind: got %q, want %q", kind, issuerKindClusterIssuer)
+	}
+	if n := len(csrClient.Actions()); n != 0 {
+		t.Errorf("got %d csr actions, want none", n)
+	}
+
+	config, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(config.AuthInfos["alice"].ClientCertificateData); got != "certificate" {
+		t.Errorf("got certificate %q, want %q", got, "certificate")
+	}
+	actions := client.Actions()
+	if last := actions[len(actions)-1]; !last.Matches("delete", "certificaterequests") {
+		t.Errorf("got last action %s, want the certificaterequest deleted", last.GetVerb())
+	}
+}
+
+func TestValidateBackendCertManager(t *testing.T) {
+	var tests = []struct {
+		name   string
+		modify func(o *CertOptions)
+	}{
+		{name: "denied-retries", modify: func(o *CertOptions) { o.deniedRetries = 2 }},
+		{name: "force-recreate-on-denied", modify: func(o *CertOptions) { o.forceRecreateOnDenied = true }},
+		{name: "skip-approve-for-signer", modify: func(o *CertOptions) { o.skipApproveSigners = []string{"example.com/custom"} }},
+		{name: "usages", modify: func(o *CertOptions) { o.usages, o.usagesSet = []string{"client auth", "server auth"}, true }},
+		{name: "signer-name", modify: func(o *CertOptions) { o.signerName, o.signerNameSet = "example.com/custom", true }},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.backend = backendCertManager
+		o.issuerName = "users"
+		if err := o.validateBackend(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		test.modify(o)
+		if err := o.validateBackend(); err == nil || !strings.Contains(err.Error(), "--"+test.name) {
+			t.Errorf("%s: got %v, want an error for --%s", test.name, err, test.name)
+		}
+	}
+}
+
+func TestCertificateRequestIssued(t *testing.T) {
+	var tests = []struct {
+		name       string
+		conditions []interface{}
+		cert       string
+		denied     bool
+		err        bool
+	}{
+		{name: "pending", conditions: []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "reason": "Pending"},
+		}},
+		{name: "issued", cert: "certificate"},
+		{name: "denied", denied: true, err: true, conditions: []interface{}{
+			map[string]interface{}{"type": "Denied", "status": "True", "reason": "Policy"},
+		}},
+		{name: "failed", err: true, conditions: []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "reason": "Failed"},
+		}},
+		{name: "invalid", err: true, conditions: []interface{}{
+			map[string]interface{}{"type": "InvalidRequest", "status": "True", "reason": "BadConfig"},
+		}},
+	}
+	for _, test := range tests {
+		cr := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		if len(test.conditions) != 0 {
+			unstructured.SetNestedSlice(cr.Object, test.conditions, "status", "conditions")
+		}
+		if len(test.cert) != 0 {
+			unstructured.SetNestedField(cr.Object, base64.StdEncoding.EncodeToString([]byte(test.cert)), "status", "certificate")
+		}
+
+		cert, err := certificateRequestIssued(cr)
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if test.denied != errors.Is(err, errCertificateDenied) {
+			t.Errorf("%s: got %v, want denied %t", test.name, err, test.denied)
+		}
+		if string(cert) != test.cert {
+			t.Errorf("%s: got %q, want %q", test.name, cert, test.cert)
+		}
+	}
+}
+
+func TestCertificateRequestName(t *testing.T) {
+	var tests = []struct {
+		csrName string
+		want    string
+	}{
+		{csrName: "alice:dev", want: "alice-dev"},
+		{csrName: "Alice:system:masters", want: "alice-system-masters"},
+		{csrName: "alice:", want: "kconfig-a343e93196f9e943"},
+	}
+	for _, test := range tests {
+		if got := certificateRequestName(test.csrName); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.csrName, got, test.want)
+		}
+	}
+}
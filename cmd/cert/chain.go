@@ -0,0 +1,42 @@
+package cert
+
+import (
+	"bytes"
+	"fmt"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const flagCAChainFile = "ca-chain-file"
+
+// validateCAChain checks that the --ca-chain-file certificates parse.
+func validateCAChain(chain []byte) error {
+	if _, err := cmdutilpkix.ParseCertificatesPem(chain); err != nil {
+		return fmt.Errorf("--%s: %v", flagCAChainFile, err)
+	}
+	return nil
+}
+
+// appendCAChain appends the intermediates of --ca-chain-file to the issued
+// certificate for signers returning the leaf only. The first intermediate
+// must have issued the last certificate returned by the signer.
+func appendCAChain(cert []byte, chain []byte) ([]byte, error) {
+	certs, err := cmdutilpkix.ParseCertificatesPem(cert)
+	if err != nil {
+		return nil, fmt.Errorf("issued certificate: %v", err)
+	}
+	intermediates, err := cmdutilpkix.ParseCertificatesPem(chain)
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %v", flagCAChainFile, err)
+	}
+
+	last := certs[len(certs)-1]
+	if !bytes.Equal(last.RawIssuer, intermediates[0].RawSubject) {
+		return nil, fmt.Errorf("the certificate %q is issued by %q, not by %q the first certificate of --%s", last.Subject, last.Issuer, intermediates[0].Subject, flagCAChainFile)
+	}
+
+	if !bytes.HasSuffix(cert, []byte("\n")) {
+		cert = append(cert, '\n')
+	}
+	return append(cert, chain...), nil
+}
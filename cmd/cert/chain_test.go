@@ -0,0 +1,113 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/clientcmd"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+// testLeaf returns a pem client certificate for alice signed by a new
+// intermediate named caName, and the pem intermediate.
+func testLeaf(t *testing.T, caName string) ([]byte, []byte) {
+	t.Helper()
+
+	caKey, caDer, err := cmdutilpkix.CreateSelfSignedCertificate(caName, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "alice"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	leafDer, err := x509.CreateCertificate(rand.Reader, template, ca, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := cmdutilpkix.PemCertificate(leafDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediate, err := cmdutilpkix.PemCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf, intermediate
+}
+
+func TestAppendCAChain(t *testing.T) {
+	leaf, intermediate := testLeaf(t, "intermediate-ca")
+	_, other := testLeaf(t, "other-ca")
+
+	var tests = []struct {
+		name  string
+		chain []byte
+		err   string
+	}{
+		{name: "chain", chain: intermediate},
+		{name: "other issuer", chain: other, err: "is issued by"},
+		{name: "not pem", chain: []byte("chain"), err: "--ca-chain-file"},
+	}
+	for _, test := range tests {
+		got, err := appendCAChain(leaf, test.chain)
+		if len(test.err) != 0 {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if !bytes.HasPrefix(got, leaf) || !bytes.HasSuffix(got, intermediate) {
+			t.Errorf("%s: got %q, want the leaf followed by the chain", test.name, got)
+		}
+		if certs, err := cmdutilpkix.ParseCertificatesPem(got); err != nil || len(certs) != 2 {
+			t.Errorf("%s: got %d certificates, %v, want 2", test.name, len(certs), err)
+		}
+	}
+}
+
+func TestWriteKubeconfigCAChain(t *testing.T) {
+	leaf, intermediate := testLeaf(t, "intermediate-ca")
+
+	o, _ := newTestCertOptions(t)
+	o.caChain = []byte("chain")
+	if err := o.Validate(); err == nil {
+		t.Error("accepted a --ca-chain-file without certificates")
+	}
+
+	o.caChain = intermediate
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.writeKubeconfig([]byte("key"), leaf); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(append([]byte{}, leaf...), intermediate...)
+	if got := config.AuthInfos["alice"].ClientCertificateData; !bytes.Equal(got, want) {
+		t.Errorf("got client certificate %q, want %q", got, want)
+	}
+}
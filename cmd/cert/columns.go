@@ -0,0 +1,99 @@
+package cert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const customColumnsPrefix = "custom-columns="
+
+type customColumn struct {
+	header string
+	path   *jsonpath.JSONPath
+}
+
+// parseCustomColumns parses HEADER:.json.path columns separated by commas,
+// as in kubectl get -o custom-columns.
+func parseCustomColumns(spec string) ([]customColumn, error) {
+	if len(spec) == 0 {
+		return nil, fmt.Errorf("custom-columns format requires at least one HEADER:.json.path column")
+	}
+
+	var columns []customColumn
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.SplitN(part, ":", 2)
+		if len(fields) != 2 || len(fields[0]) == 0 || len(fields[1]) == 0 {
+			return nil, fmt.Errorf("invalid custom column %q, must be HEADER:.json.path", part)
+		}
+		expr := fields[1]
+		if !strings.HasPrefix(expr, "{") {
+			expr = "{" + expr + "}"
+		}
+		path := jsonpath.New(fields[0]).AllowMissingKeys(true)
+		if err := path.Parse(expr); err != nil {
+			return nil, fmt.Errorf("custom column %q: %v", fields[0], err)
+		}
+		columns = append(columns, customColumn{header: fields[0], path: path})
+	}
+	return columns, nil
+}
+
+// csrColumnObject returns the json fields of the csr for the columns, with
+// the validity of an issued certificate under .certificate.
+func csrColumnObject(csr *certificatesv1.CertificateSigningRequest) (map[string]interface{}, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(csr)
+	if err != nil {
+		return nil, err
+	}
+
+	if certs, err := cmdutilpkix.ParseCertificatesPem(csr.Status.Certificate); err == nil {
+		obj["certificate"] = map[string]interface{}{
+			"subject":   certs[0].Subject.String(),
+			"serial":    serialHex(certs[0]),
+			"notBefore": certs[0].NotBefore.UTC().Format(time.RFC3339),
+			"notAfter":  certs[0].NotAfter.UTC().Format(time.RFC3339),
+		}
+	}
+	return obj, nil
+}
+
+func printCustomColumns(out io.Writer, columns []customColumn, csrs []certificatesv1.CertificateSigningRequest) error {
+	w := tabwriter.NewWriter(out, 0, 8, 3, ' ', 0)
+
+	headers := make([]string, 0, len(columns))
+	for _, column := range columns {
+		headers = append(headers, column.header)
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+
+	for i := range csrs {
+		obj, err := csrColumnObject(&csrs[i])
+		if err != nil {
+			return err
+		}
+		values := make([]string, 0, len(columns))
+		for _, column := range columns {
+			var value bytes.Buffer
+			if err := column.path.Execute(&value, obj); err != nil {
+				return fmt.Errorf("custom column %q of csr %q: %v", column.header, csrs[i].Name, err)
+			}
+			if value.Len() == 0 {
+				value.WriteString("<none>")
+			}
+			values = append(values, value.String())
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,95 @@
+package cert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+func TestParseCustomColumns(t *testing.T) {
+	var tests = []struct {
+		spec    string
+		headers []string
+		err     bool
+	}{
+		{spec: "NAME:.metadata.name,USER:{.spec.username}", headers: []string{"NAME", "USER"}},
+		{spec: "", err: true},
+		{spec: "NAME", err: true},
+		{spec: ":.metadata.name", err: true},
+		{spec: "NAME:.metadata[", err: true},
+	}
+	for _, test := range tests {
+		columns, err := parseCustomColumns(test.spec)
+		if test.err != (err != nil) {
+			t.Errorf("%q: unexpected error %v", test.spec, err)
+			continue
+		}
+		var headers []string
+		for _, column := range columns {
+			headers = append(headers, column.header)
+		}
+		if strings.Join(headers, ",") != strings.Join(test.headers, ",") {
+			t.Errorf("%q: got headers %q, want %q", test.spec, headers, test.headers)
+		}
+	}
+}
+
+func TestRunListCustomColumns(t *testing.T) {
+	_, der, err := cmdutilpkix.CreateSelfSignedCertificate("alice", []string{"dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := cmdutilpkix.PemCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]string{labelCreator: creatorKconfig}
+	client := fake.NewSimpleClientset(
+		&certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice:dev", Labels: labels},
+			Spec:       certificatesv1.CertificateSigningRequestSpec{Username: "alice"},
+			Status:     certificatesv1.CertificateSigningRequestStatus{Certificate: cert},
+		},
+		&certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "bob:ops", Labels: labels},
+			Spec:       certificatesv1.CertificateSigningRequestSpec{Username: "bob"},
+		},
+	)
+
+	var out bytes.Buffer
+	o := ListOptions{
+		clientSet: client,
+		creator:   creatorKconfig,
+		limit:     defaultListLimit,
+		output:    customColumnsPrefix + "NAME:.metadata.name,USER:.spec.username,SERIAL:.certificate.serial",
+		out:       &out,
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := [][]string{{"NAME", "USER", "SERIAL"}, {"alice:dev", "alice", "7e5"}, {"bob:ops", "bob", "<none>"}}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q", out.String())
+	}
+	for i, line := range lines {
+		if got := strings.Fields(line); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("line %d: got %q, want %q", i, got, want[i])
+		}
+	}
+
+	o.output = "wide"
+	if err := o.Validate(); err == nil {
+		t.Error("accepted output format wide")
+	}
+}
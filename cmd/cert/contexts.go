@@ -0,0 +1,117 @@
+package cert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+)
+
+const (
+	flagDescription = "description"
+
+	// extensionContext names the context extension kconfig records the
+	// description and creation time of its contexts in.
+	extensionContext = "kconfig.local.io/context"
+)
+
+type contextExtension struct {
+	Description string `json:"description"`
+	Created     string `json:"created"`
+}
+
+type ContextsOptions struct {
+	configAccess clientcmd.ConfigAccess
+	out          io.Writer
+}
+
+func NewCmdCertContexts(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	o := ContextsOptions{
+		out: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "contexts",
+		Short: "List the contexts of the current kubeconfig file.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(configFlags))
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	return cmd
+}
+
+func (o *ContextsOptions) Complete(configFlags *genericclioptions.ConfigFlags) error {
+	o.configAccess = configFlags.ToRawKubeConfigLoader().ConfigAccess()
+	return nil
+}
+
+func (o *ContextsOptions) Run() error {
+	config, err := loadStartingConfig(o.configAccess)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(o.out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tCLUSTER\tAUTHINFO\tNAMESPACE\tDESCRIPTION")
+	for _, name := range names {
+		ctx := config.Contexts[name]
+		current := ""
+		if name == config.CurrentContext {
+			current = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", current, name, ctx.Cluster, ctx.AuthInfo, ctx.Namespace, contextDescription(ctx))
+	}
+
+	return w.Flush()
+}
+
+// describeContext records the description and creation time in the kconfig
+// extension of the context.
+func describeContext(ctx *clientcmdapi.Context, description string, created time.Time) error {
+	raw, err := json.Marshal(contextExtension{
+		Description: description,
+		Created:     created.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	if ctx.Extensions == nil {
+		ctx.Extensions = map[string]runtime.Object{}
+	}
+	ctx.Extensions[extensionContext] = &runtime.Unknown{Raw: raw, ContentType: runtime.ContentTypeJSON}
+	return nil
+}
+
+// contextDescription returns the description of the kconfig extension of the
+// context, if any.
+func contextDescription(ctx *clientcmdapi.Context) string {
+	unknown, ok := ctx.Extensions[extensionContext].(*runtime.Unknown)
+	if !ok {
+		return ""
+	}
+	var extension contextExtension
+	if err := json.Unmarshal(unknown.Raw, &extension); err != nil {
+		return ""
+	}
+	return extension.Description
+}
@@ -0,0 +1,89 @@
+package cert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+type postHookData struct {
+	Kubeconfig string
+	Username   string
+	Expiry     string
+}
+
+func parsePostHook(text string) (*template.Template, error) {
+	tmpl, err := template.New(flagPostHook).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("--%s: %v", flagPostHook, err)
+	}
+	return tmpl, nil
+}
+
+// runPostHook renders the hook command and runs it with sh, failing on a
+// non-zero exit code. The values are passed in KCONFIG_KUBECONFIG,
+// KCONFIG_USERNAME and KCONFIG_EXPIRY, the template fields expand to those
+// variables, so user names or paths with shell syntax are never run; quote
+// them like any shell variable.
+func runPostHook(text string, data postHookData, stdout, stderr io.Writer) error {
+	tmpl, err := parsePostHook(text)
+	if err != nil {
+		return err
+	}
+
+	var command strings.Builder
+	err = tmpl.Execute(&command, postHookData{
+		Kubeconfig: "${KCONFIG_KUBECONFIG}",
+		Username:   "${KCONFIG_USERNAME}",
+		Expiry:     "${KCONFIG_EXPIRY}",
+	})
+	if err != nil {
+		return fmt.Errorf("--%s: %v", flagPostHook, err)
+	}
+
+	klog.V(2).InfoS("run post hook", "command", command.String())
+	cmd := exec.Command("sh", "-c", command.String())
+	cmd.Env = append(os.Environ(),
+		"KCONFIG_KUBECONFIG="+data.Kubeconfig,
+		"KCONFIG_USERNAME="+data.Username,
+		"KCONFIG_EXPIRY="+data.Expiry,
+	)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("post hook %q exited with code %d", command.String(), exitErr.ExitCode())
+	}
+	return err
+}
+
+func (o *CertOptions) runPostHook(cert []byte) error {
+	data := postHookData{
+		Kubeconfig: o.output,
+		Username:   o.userName,
+	}
+	if o.merge {
+		data.Kubeconfig = o.mergeTarget()
+	}
+	if certs, err := cmdutilpkix.ParseCertificatesPem(cert); err == nil {
+		data.Expiry = certs[0].NotAfter.UTC().Format(time.RFC3339)
+	}
+
+	err := runPostHook(o.postHook, data, o.errOut, o.errOut)
+	if err != nil && o.ignoreHookFailure {
+		klog.Warningf("ignoring post hook failure: %v", err)
+		return nil
+	}
+	return err
+}
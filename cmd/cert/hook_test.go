@@ -0,0 +1,39 @@
+package cert
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRunPostHook(t *testing.T) {
+	var tests = []struct {
+		hook     string
+		username string
+		err      string
+	}{
+		{hook: `test "{{.Username}}" = alice && test "{{.Kubeconfig}}" = alice.config`},
+		{hook: `test {{.Username}} = alice && test "$KCONFIG_KUBECONFIG" = alice.config`},
+		{hook: `test {{.Username}} = 'alice;exit'`, username: "alice;exit"},
+		{hook: `test "{{.Username}}" = '$(exit 3)'`, username: "$(exit 3)"},
+		{hook: "exit 3", err: "exited with code 3"},
+		{hook: "{{.Unknown}}", err: "Unknown"},
+	}
+	for _, test := range tests {
+		username := test.username
+		if len(username) == 0 {
+			username = "alice"
+		}
+		err := runPostHook(test.hook, postHookData{Kubeconfig: "alice.config", Username: username}, io.Discard, io.Discard)
+		if len(test.err) == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", test.hook, err)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.hook, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cert
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+const flagInheritGroups = "inherit-groups"
+
+// inheritTokenGroups adds the groups the apiserver reads from the operator's
+// bearer token to the requested groups. Without a token, e.g. for client
+// certificates or exec plugins, or without a SelfSubjectReview to read them
+// with, only the explicit groups are requested.
+func (o *CertOptions) inheritTokenGroups() error {
+	token, err := o.bearerToken()
+	if err != nil {
+		return err
+	}
+	if len(token) == 0 {
+		klog.Warningf("--%s: the current user authenticates without a bearer token, requesting the explicit groups only", flagInheritGroups)
+		return nil
+	}
+
+	userInfo, err := reviewSelf(o.clientSet)
+	if apierrors.IsForbidden(err) {
+		klog.Warningf("--%s: the current user may not review itself, requesting the explicit groups only: %v", flagInheritGroups, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("--%s: %v", flagInheritGroups, err)
+	}
+	if userInfo == nil {
+		klog.Warningf("--%s: the server does not serve SelfSubjectReview, requesting the explicit groups only", flagInheritGroups)
+		return nil
+	}
+	var inherited []string
+	for _, group := range userInfo.Groups {
+		// the apiserver adds system:authenticated and alike by itself, the
+		// certificate would claim reserved groups otherwise
+		if !strings.HasPrefix(group, systemIdentityPrefix) {
+			inherited = append(inherited, group)
+		}
+	}
+	o.groups, inherited = unionGroups(o.groups, inherited)
+	klog.V(2).InfoS("inherit groups of the bearer token", "groups", inherited)
+	return nil
+}
+
+// bearerToken returns the token kconfig talks to the cluster with, the id
+// token of the oidc auth provider included.
+func (o *CertOptions) bearerToken() (string, error) {
+	if len(o.restConfig.BearerToken) != 0 {
+		return o.restConfig.BearerToken, nil
+	}
+	if len(o.restConfig.BearerTokenFile) != 0 {
+		token, err := os.ReadFile(o.restConfig.BearerTokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+	if o.restConfig.AuthProvider != nil {
+		return o.restConfig.AuthProvider.Config["id-token"], nil
+	}
+	return "", nil
+}
@@ -0,0 +1,122 @@
+package cert
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestInheritTokenGroups(t *testing.T) {
+	var tests = []struct {
+		name        string
+		restConfig  *rest.Config
+		userInfo    *authenticationv1.UserInfo
+		status      error
+		wantGroups  []string
+		wantCSRName string
+		wantReviews []string
+		err         bool
+	}{
+		{
+			name:        "self subject review",
+			restConfig:  &rest.Config{BearerToken: "token"},
+			userInfo:    &authenticationv1.UserInfo{Username: "alice", Groups: []string{"ops", "dev", "system:authenticated"}},
+			wantGroups:  []string{"dev", "ops"},
+			wantCSRName: "alice:dev:ops",
+			wantReviews: []string{"v1"},
+		},
+		{
+			name:        "oidc id token",
+			restConfig:  &rest.Config{AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "oidc", Config: map[string]string{"id-token": "id-token"}}},
+			userInfo:    &authenticationv1.UserInfo{Groups: []string{"sre"}},
+			wantGroups:  []string{"dev", "sre"},
+			wantCSRName: "alice:dev:sre",
+			wantReviews: []string{"v1"},
+		},
+		{
+			name:        "forbidden requests the explicit groups",
+			restConfig:  &rest.Config{BearerToken: "token"},
+			status:      apierrors.NewForbidden(schema.GroupResource{Group: "authentication.k8s.io", Resource: "selfsubjectreviews"}, "", nil),
+			wantGroups:  []string{"dev"},
+			wantCSRName: "alice:dev",
+			wantReviews: []string{"v1"},
+		},
+		{
+			name:        "not served requests the explicit groups",
+			restConfig:  &rest.Config{BearerToken: "token"},
+			status:      apierrors.NewNotFound(schema.GroupResource{Group: "authentication.k8s.io", Resource: "selfsubjectreviews"}, ""),
+			wantGroups:  []string{"dev"},
+			wantCSRName: "alice:dev",
+			wantReviews: []string{"v1", "v1beta1", "v1alpha1"},
+		},
+		{
+			name:        "client certificate",
+			restConfig:  &rest.Config{TLSClientConfig: rest.TLSClientConfig{CertData: []byte("certificate")}},
+			wantGroups:  []string{"dev"},
+			wantCSRName: "alice:dev",
+		},
+		{
+			name:        "not authenticated",
+			restConfig:  &rest.Config{BearerToken: "expired"},
+			status:      apierrors.NewUnauthorized("token expired"),
+			wantReviews: []string{"v1"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		var reviews []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var review selfSubjectReview
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &review); err != nil || review.Kind != "SelfSubjectReview" {
+				t.Errorf("%s: %s %s: unexpected body %s", test.name, r.Method, r.URL.Path, body)
+			}
+			version := strings.TrimPrefix(review.APIVersion, authenticationv1.GroupName+"/")
+			if r.Method != http.MethodPost || r.URL.Path != "/apis/authentication.k8s.io/"+version+"/selfsubjectreviews" {
+				t.Errorf("%s: unexpected call %s %s", test.name, r.Method, r.URL.Path)
+			}
+			reviews = append(reviews, version)
+
+			w.Header().Set("Content-Type", "application/json")
+			if test.status != nil {
+				writeStatus(w, test.status)
+				return
+			}
+			review.Status.UserInfo = *test.userInfo
+			json.NewEncoder(w).Encode(review)
+		}))
+
+		o, _ := newTestCertOptions(t)
+		o.restConfig = test.restConfig
+		o.clientSet = kubernetes.NewForConfigOrDie(&rest.Config{Host: server.URL})
+
+		err := o.inheritTokenGroups()
+		server.Close()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if !reflect.DeepEqual(reviews, test.wantReviews) {
+			t.Errorf("%s: got reviews %q, want %q", test.name, reviews, test.wantReviews)
+		}
+		if err != nil {
+			continue
+		}
+		if err := o.completeNames(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(o.groups, test.wantGroups) || o.csrName != test.wantCSRName {
+			t.Errorf("%s: got groups %q and csr %q, want %q and %q", test.name, o.groups, o.csrName, test.wantGroups, test.wantCSRName)
+		}
+	}
+}
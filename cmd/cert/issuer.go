@@ -0,0 +1,104 @@
+package cert
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+)
+
+// certificateIssuer issues the certificate of the user through a signing
+// request and removes the request once the kubeconfig is written.
+type certificateIssuer interface {
+	// issue returns the private key, if known, and the issued certificate.
+	issue() ([]byte, []byte, error)
+	delete() error
+}
+
+func (o *CertOptions) issuer() certificateIssuer {
+	if o.backend == backendCertManager {
+		return certManagerIssuer{o}
+	}
+	return csrIssuer{o}
+}
+
+// deleteNoticeMarker is created next to the config file once the notice of
+// the --delete-on-success default was shown.
+const deleteNoticeMarker = ".delete-on-success-notice"
+
+func addDeleteOnSuccessFlag(cmd *cobra.Command, deleteOnSuccess *bool) {
+	cmd.Flags().BoolVar(deleteOnSuccess, flagDeleteOnSuccess, true, "delete the signing request once the certificate is written - NOTE a future version may default to keeping it, set the flag explicitly to keep the current behavior")
+}
+
+// deleteIssued is the single place deciding whether the signing request of
+// an issued and written certificate is deleted.
+func (o *CertOptions) deleteIssued(issuer certificateIssuer) error {
+	if !o.deleteOnSuccess {
+		klog.V(2).InfoS("keep signing request", "phase", "delete", "duration", time.Since(o.start))
+		return nil
+	}
+	if !o.deleteOnSuccessSet {
+		o.noticeDeleteOnSuccess()
+	}
+	return issuer.delete()
+}
+
+// noticeDeleteOnSuccess prints the notice of the changing default once per
+// process and, with a config file, once for its directory.
+func (o *CertOptions) noticeDeleteOnSuccess() {
+	if o.deleteNoticeShown {
+		return
+	}
+	o.deleteNoticeShown = true
+
+	var marker string
+	if len(o.configFile) != 0 {
+		marker = filepath.Join(filepath.Dir(o.configFile), deleteNoticeMarker)
+		if _, err := os.Stat(marker); err == nil {
+			return
+		}
+	}
+	fmt.Fprintf(o.errOut, "NOTICE: deleting the signing request, a future version may keep it by default - set --%s=true or --%s=false to choose.\n", flagDeleteOnSuccess, flagDeleteOnSuccess)
+	if len(marker) == 0 {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(marker), 0700)
+	if err == nil {
+		err = os.WriteFile(marker, nil, 0600)
+	}
+	if err != nil {
+		klog.V(2).InfoS("unable to record the notice", "marker", marker, "err", err)
+	}
+}
+
+// deleteOnPanic deletes the request of a panicking run, which would leak
+// otherwise, and panics again.
+func deleteOnPanic(issuer certificateIssuer) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	klog.ErrorS(nil, "panic, deleting the signing request", "panic", r)
+	if err := issuer.delete(); err != nil && !apierrors.IsNotFound(err) {
+		klog.ErrorS(err, "delete signing request after panic")
+	}
+	panic(r)
+}
+
+// csrIssuer uses a cluster scoped certificates.k8s.io/v1 csr.
+type csrIssuer struct {
+	o *CertOptions
+}
+
+func (i csrIssuer) issue() ([]byte, []byte, error) {
+	return i.o.issue()
+}
+
+func (i csrIssuer) delete() error {
+	klog.V(2).InfoS("delete csr", "csr", i.o.csrName, "phase", "delete", "duration", time.Since(i.o.start))
+	return i.o.deleteCertificatesV1CertificateSigningRequest()
+}
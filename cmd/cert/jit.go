@@ -0,0 +1,39 @@
+package cert
+
+import (
+	"fmt"
+	"time"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const (
+	flagJIT = "jit"
+
+	jitExpiration = 15 * time.Minute
+
+	annotationBreakGlass = "kconfig.local.io/break-glass"
+)
+
+// applyJIT presets short-lived break-glass access: a 15m certificate whose
+// csr is kept for audit, unless --expiration or --delete-on-success are set.
+func (o *CertOptions) applyJIT() {
+	if !o.jit {
+		return
+	}
+	if o.expiration == 0 {
+		o.expiration = jitExpiration
+	}
+	if !o.deleteOnSuccessSet {
+		o.deleteOnSuccess = false
+	}
+}
+
+// printJITExpiry tells when the break-glass certificate expires.
+func (o *CertOptions) printJITExpiry(cert []byte) {
+	notAfter := o.start.Add(o.expiration)
+	if certs, err := cmdutilpkix.ParseCertificatesPem(cert); err == nil {
+		notAfter = certs[0].NotAfter
+	}
+	fmt.Fprintf(o.errOut, "BREAK-GLASS: the certificate of %q expires at %s, in %s.\n", o.userName, notAfter.UTC().Format(time.RFC3339), time.Until(notAfter).Round(time.Second))
+}
@@ -0,0 +1,69 @@
+package cert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestApplyJIT(t *testing.T) {
+	var tests = []struct {
+		name               string
+		expiration         time.Duration
+		deleteOnSuccessSet bool
+		wantExpiration     time.Duration
+		wantDelete         bool
+	}{
+		{name: "preset", wantExpiration: jitExpiration},
+		{name: "expiration", expiration: time.Hour, wantExpiration: time.Hour},
+		{name: "delete on success", deleteOnSuccessSet: true, wantExpiration: jitExpiration, wantDelete: true},
+	}
+	for _, test := range tests {
+		o := CertOptions{
+			jit:                true,
+			expiration:         test.expiration,
+			deleteOnSuccess:    true,
+			deleteOnSuccessSet: test.deleteOnSuccessSet,
+		}
+		o.applyJIT()
+		if o.expiration != test.wantExpiration || o.deleteOnSuccess != test.wantDelete {
+			t.Errorf("%s: got expiration %s, delete %t, want %s, %t", test.name, o.expiration, o.deleteOnSuccess, test.wantExpiration, test.wantDelete)
+		}
+	}
+}
+
+func TestRunJIT(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.jit = true
+	o.deleteOnSuccessSet = false
+	o.applyJIT()
+	var errOut strings.Builder
+	o.errOut = &errOut
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range client.Actions() {
+		if action.Matches("create", "certificatesigningrequests") {
+			csr := action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+			if csr.Annotations[annotationBreakGlass] != "true" {
+				t.Errorf("got annotations %v, want %s", csr.Annotations, annotationBreakGlass)
+			}
+			if seconds := csr.Spec.ExpirationSeconds; seconds == nil || *seconds != int32(jitExpiration.Seconds()) {
+				t.Errorf("got expiration seconds %v, want %d", seconds, int32(jitExpiration.Seconds()))
+			}
+		}
+	}
+	if n := countActions(client, "delete"); n != 0 {
+		t.Errorf("got %d deletes, want the csr kept", n)
+	}
+	if !strings.Contains(errOut.String(), "BREAK-GLASS") {
+		t.Errorf("expiry not printed: %q", errOut.String())
+	}
+}
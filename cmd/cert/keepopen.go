@@ -0,0 +1,128 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const flagWaitAndKeepOpen = "wait-and-keep-open"
+
+var (
+	// renewRetryInitial and renewRetryMax bound the backoff between failed
+	// renewals.
+	renewRetryInitial = 10 * time.Second
+	renewRetryMax     = 5 * time.Minute
+)
+
+func (o *CertOptions) validateKeepOpen() error {
+	if o.renewBefore < 0 {
+		return fmt.Errorf("--%s must not be negative", flagRenewBefore)
+	}
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{o.offline, flagOffline},
+		{o.requestOnly, flagRequestOnly},
+		{o.watchExisting, flagWatchExisting},
+		{o.dryRun != dryRunNone, flagDryRun},
+		{o.allowExistingContext, flagAllowExistingCtx},
+		{o.format == formatRequestJSON, flagFormat + "=" + formatRequestJSON},
+	} {
+		if f.set {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", flagWaitAndKeepOpen, f.name)
+		}
+	}
+	if !o.merge && len(o.output) == 0 && o.format != formatSystemdCreds {
+		return fmt.Errorf("--%s requires a kubeconfig file to rewrite, set --%s or --%s", flagWaitAndKeepOpen, flagOutput, flagMerge)
+	}
+	if o.merge && o.onConflict != onConflictOverwrite {
+		return fmt.Errorf("--%s with --%s requires --%s=%s to replace the renewed user", flagWaitAndKeepOpen, flagMerge, flagOnConflict, onConflictOverwrite)
+	}
+	return nil
+}
+
+// runKeepOpen renews the certificate until SIGINT or SIGTERM.
+func (o *CertOptions) runKeepOpen() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return o.keepRenewing(ctx)
+}
+
+// keepRenewing issues the certificate and rewrites the kubeconfig, then
+// again once it needs renewal by --renew-before, until ctx is done. The
+// kubeconfig is replaced atomically, so readers never see a partial file.
+// A failed renewal is retried with backoff while the written certificate is
+// still valid.
+func (o *CertOptions) keepRenewing(ctx context.Context) error {
+	c := o.clock
+	if c == nil {
+		c = clock.RealClock{}
+	}
+
+	var expiry time.Time
+	backoff := renewRetryInitial
+	for {
+		o.start = time.Now()
+		cert, err := o.issueAndWrite()
+		if err != nil {
+			if expiry.IsZero() || !c.Now().Add(backoff).Before(expiry) {
+				return err
+			}
+			klog.ErrorS(err, "renewal failed, retrying", "backoff", backoff, "expiry", expiry)
+			if !sleep(ctx, c, backoff) {
+				klog.InfoS("renewal stopped")
+				return nil
+			}
+			if backoff *= 2; backoff > renewRetryMax {
+				backoff = renewRetryMax
+			}
+			continue
+		}
+		backoff = renewRetryInitial
+
+		var renewAt time.Time
+		renewAt, expiry, err = renewalTime(cert, o.renewBefore)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(o.errOut, "Certificate of %q written, renewing at %s.\n", o.userName, renewAt.UTC().Format(time.RFC3339))
+
+		if !sleep(ctx, c, renewAt.Sub(c.Now())) {
+			klog.InfoS("renewal stopped")
+			return nil
+		}
+	}
+}
+
+// sleep waits for d on the clock, returning false when ctx is done first.
+func sleep(ctx context.Context, c clock.Clock, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-c.After(d):
+		return true
+	}
+}
+
+// renewalTime returns when to renew the certificate, when needsRenewal by
+// renewBefore or, for certificates valid for less, after two thirds of its
+// validity, and when it expires.
+func renewalTime(cert []byte, renewBefore time.Duration) (time.Time, time.Time, error) {
+	certs, err := cmdutilpkix.ParseCertificatesPem(cert)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("unable to schedule the renewal: %v", err)
+	}
+
+	return renewalStart(certs[0], renewBefore), certs[0].NotAfter, nil
+}
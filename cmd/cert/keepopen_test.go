@@ -0,0 +1,216 @@
+package cert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+// testCertificate returns a pem certificate for alice of the organizations
+// valid for validity from notBefore.
+func testCertificate(t *testing.T, notBefore time.Time, validity time.Duration, organizations ...string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(notBefore.Unix()),
+		Subject:      pkix.Name{CommonName: "alice", Organization: organizations},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(validity),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := cmdutilpkix.PemCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestRenewalTime(t *testing.T) {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := testCertificate(t, notBefore, 3*time.Hour)
+
+	var tests = []struct {
+		renewBefore time.Duration
+		want        time.Time
+	}{
+		{want: notBefore.Add(3 * time.Hour)},
+		{renewBefore: 30 * time.Minute, want: notBefore.Add(150 * time.Minute)},
+		{renewBefore: defaultRenewBefore, want: notBefore.Add(2 * time.Hour)},
+	}
+	for _, test := range tests {
+		got, expiry, err := renewalTime(cert, test.renewBefore)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%s: got %s, want %s", test.renewBefore, got, test.want)
+		}
+		if !expiry.Equal(notBefore.Add(3 * time.Hour)) {
+			t.Errorf("%s: got expiry %s", test.renewBefore, expiry)
+		}
+	}
+
+	if _, _, err := renewalTime([]byte("certificate"), 0); err == nil {
+		t.Error("scheduled the renewal of an unparsable certificate")
+	}
+}
+
+func TestKeepRenewing(t *testing.T) {
+	fakeClock := clocktesting.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	o, client := newTestCertOptions(t)
+	o.waitAndKeepOpen = true
+	o.clock = fakeClock
+	client.PrependReactor("update", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "approval" {
+			return false, nil, nil
+		}
+		csr := action.(k8stesting.UpdateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		csr.Status.Certificate = testCertificate(t, fakeClock.Now(), time.Hour)
+		return true, csr, client.Tracker().Update(certificatesv1.SchemeGroupVersion.WithResource("certificatesigningrequests"), csr, "")
+	})
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- o.keepRenewing(ctx)
+	}()
+
+	var serials []string
+	for cycle := 0; cycle < 2; cycle++ {
+		for !fakeClock.HasWaiters() {
+			time.Sleep(time.Millisecond)
+		}
+		config, err := clientcmd.LoadFromFile(o.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serial, err := certificateSerial(config.AuthInfos["alice"].ClientCertificateData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		serials = append(serials, serial)
+
+		if cycle == 0 {
+			fakeClock.Step(time.Hour)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if n := countActions(client, "create"); n != 2 {
+		t.Errorf("got %d csrs created, want 2", n)
+	}
+	if len(serials) != 2 || serials[0] == serials[1] {
+		t.Errorf("got serials %q, want the kubeconfig rewritten with a new certificate", serials)
+	}
+
+	o.output = ""
+	if err := o.Validate(); err == nil {
+		t.Error("accepted --wait-and-keep-open writing to stdout")
+	}
+}
+
+func TestKeepRenewingRetries(t *testing.T) {
+	var tests = []struct {
+		name     string
+		failures int
+		wantErr  bool
+	}{
+		{name: "transient", failures: 2},
+		{name: "until expiry", failures: 1000, wantErr: true},
+	}
+	for _, test := range tests {
+		fakeClock := clocktesting.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+		o, client := newTestCertOptions(t)
+		o.waitAndKeepOpen = true
+		o.renewBefore = 30 * time.Minute
+		o.clock = fakeClock
+		creates := 0
+		client.PrependReactor("create", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			creates++
+			if creates > 1 && creates <= 1+test.failures {
+				return true, nil, errors.New("connection refused")
+			}
+			return false, nil, nil
+		})
+		client.PrependReactor("update", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if action.GetSubresource() != "approval" {
+				return false, nil, nil
+			}
+			csr := action.(k8stesting.UpdateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+			csr.Status.Certificate = testCertificate(t, fakeClock.Now(), time.Hour)
+			return true, csr, client.Tracker().Update(certificatesv1.SchemeGroupVersion.WithResource("certificatesigningrequests"), csr, "")
+		})
+		if err := o.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- o.keepRenewing(ctx)
+		}()
+
+		var err error
+	wait:
+		for {
+			select {
+			case err = <-done:
+				break wait
+			default:
+			}
+			if !fakeClock.HasWaiters() {
+				time.Sleep(time.Millisecond)
+				continue
+			}
+			if countActions(client, "create") == 2+test.failures {
+				cancel()
+				continue
+			}
+			fakeClock.Step(renewRetryInitial)
+		}
+		cancel()
+
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: kept retrying past the expiry", test.name)
+			}
+			if !fakeClock.Now().Before(time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)) {
+				t.Errorf("%s: gave up at %s, after the certificate expired", test.name, fakeClock.Now())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
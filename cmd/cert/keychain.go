@@ -0,0 +1,190 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+)
+
+const (
+	flagStore   = "store"
+	flagAccount = "account"
+
+	storeKubeconfig = "kubeconfig"
+	storeKeychain   = "keychain"
+
+	keychainService = "kconfig"
+)
+
+// keychain stores secrets of the kconfig service in the os keychain.
+type keychain interface {
+	Set(account string, secret []byte) error
+	Get(account string) ([]byte, error)
+}
+
+var newKeychain = func() (keychain, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return securityKeychain{}, nil
+	case "linux", "freebsd", "openbsd":
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return nil, errors.New("the keychain store needs secret-tool from libsecret")
+		}
+		return secretToolKeychain{}, nil
+	}
+	return nil, fmt.Errorf("the keychain store is not supported on %s, use --%s to keep the key in a file instead", runtime.GOOS, flagKeyOut)
+}
+
+// securityKeychain uses the macOS security tool.
+type securityKeychain struct{}
+
+// Set runs security in interactive mode and writes the command on stdin,
+// keeping the secret out of the argv visible to ps.
+func (securityKeychain) Set(account string, secret []byte) error {
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		keychainService, securityQuote(account), base64.StdEncoding.EncodeToString(secret)))
+	return cmd.Run()
+}
+
+// securityQuote double quotes an argument for the command line parser of
+// security -i.
+func securityQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (securityKeychain) Get(account string) ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
+
+// secretToolKeychain uses secret-tool of libsecret.
+type secretToolKeychain struct{}
+
+func (secretToolKeychain) Set(account string, secret []byte) error {
+	cmd := exec.Command("secret-tool", "store", "--label", keychainService+" "+account, "service", keychainService, "account", account)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(secret))
+	return cmd.Run()
+}
+
+func (secretToolKeychain) Get(account string) ([]byte, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", account).Output()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+}
+
+// storeInKeychain saves the key and certificate of the user in the keychain
+// and makes the user run `kconfig cert credential` to retrieve them.
+func storeInKeychain(k keychain, authInfo *clientcmdapi.AuthInfo, account string) error {
+	bundle := append(append([]byte{}, authInfo.ClientCertificateData...), authInfo.ClientKeyData...)
+	err := k.Set(account, bundle)
+	if err != nil {
+		return fmt.Errorf("unable to store the key of %q in the keychain: %v", account, err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		executable = "kconfig"
+	}
+	authInfo.ClientKeyData = nil
+	authInfo.ClientCertificateData = nil
+	authInfo.Exec = &clientcmdapi.ExecConfig{
+		APIVersion:      clientauthenticationv1beta1.SchemeGroupVersion.String(),
+		Command:         executable,
+		Args:            []string{"cert", "credential", "--" + flagAccount, account},
+		InteractiveMode: clientcmdapi.NeverExecInteractiveMode,
+	}
+	return nil
+}
+
+type CredentialOptions struct {
+	account string
+
+	out io.Writer
+}
+
+func NewCmdCertCredential() *cobra.Command {
+	o := CredentialOptions{
+		out: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:    "credential",
+		Short:  "Print the keychain stored certificate of an account as exec credential.",
+		Hidden: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.account, flagAccount, "", "keychain account, the context name of the credential")
+	cmd.MarkFlagRequired(flagAccount)
+
+	return cmd
+}
+
+func (o *CredentialOptions) Run() error {
+	k, err := newKeychain()
+	if err != nil {
+		return err
+	}
+	bundle, err := k.Get(o.account)
+	if err != nil {
+		return fmt.Errorf("unable to read %q from the keychain: %v", o.account, err)
+	}
+
+	return writeExecCredential(o.out, bundle)
+}
+
+// writeExecCredential splits the keychain bundle into certificates and key
+// and prints them as client.authentication.k8s.io ExecCredential.
+func writeExecCredential(out io.Writer, bundle []byte) error {
+	var cert, key bytes.Buffer
+	for {
+		var block *pem.Block
+		block, bundle = pem.Decode(bundle)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			pem.Encode(&cert, block)
+		} else {
+			pem.Encode(&key, block)
+		}
+	}
+	if cert.Len() == 0 || key.Len() == 0 {
+		return errors.New("keychain entry does not hold a certificate and key")
+	}
+
+	return json.NewEncoder(out).Encode(clientauthenticationv1beta1.ExecCredential{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: clientauthenticationv1beta1.SchemeGroupVersion.String(),
+			Kind:       "ExecCredential",
+		},
+		Status: &clientauthenticationv1beta1.ExecCredentialStatus{
+			ClientCertificateData: cert.String(),
+			ClientKeyData:         key.String(),
+		},
+	})
+}
@@ -0,0 +1,111 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	clientauthenticationv1beta1 "k8s.io/client-go/pkg/apis/clientauthentication/v1beta1"
+	"k8s.io/client-go/tools/clientcmd"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+type fakeKeychain map[string][]byte
+
+func (k fakeKeychain) Set(account string, secret []byte) error {
+	k[account] = secret
+	return nil
+}
+
+func (k fakeKeychain) Get(account string) ([]byte, error) {
+	secret, ok := k[account]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return secret, nil
+}
+
+func TestRunStoreKeychain(t *testing.T) {
+	k := fakeKeychain{}
+	defer func(f func() (keychain, error)) { newKeychain = f }(newKeychain)
+	newKeychain = func() (keychain, error) { return k, nil }
+
+	_, der, err := cmdutilpkix.CreateSelfSignedCertificate("alice", []string{"dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := cmdutilpkix.PemCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, _ := newTestCertOptions(t)
+	o.store = storeKeychain
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	key, _, err := o.createCertificateRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := o.writeKubeconfig(key, cert); err != nil {
+		t.Fatal(err)
+	}
+
+	kubeconfig, err := clientcmd.LoadFromFile(o.output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authInfo := kubeconfig.AuthInfos["alice"]
+	if len(authInfo.ClientKeyData) != 0 || len(authInfo.ClientCertificateData) != 0 {
+		t.Error("key or certificate embedded in the kubeconfig")
+	}
+	if authInfo.Exec == nil || authInfo.Exec.Args[len(authInfo.Exec.Args)-1] != "alice@local" {
+		t.Fatalf("unexpected exec config %+v", authInfo.Exec)
+	}
+
+	var out bytes.Buffer
+	c := CredentialOptions{account: "alice@local", out: &out}
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	var credential clientauthenticationv1beta1.ExecCredential
+	if err := json.Unmarshal(out.Bytes(), &credential); err != nil {
+		t.Fatal(err)
+	}
+	if credential.Status.ClientCertificateData != string(cert) || credential.Status.ClientKeyData != string(key) {
+		t.Error("exec credential does not carry the stored certificate and key")
+	}
+}
+
+func TestValidateStoreKeychain(t *testing.T) {
+	defer func(f func() (keychain, error)) { newKeychain = f }(newKeychain)
+	newKeychain = func() (keychain, error) {
+		return nil, errors.New("the keychain store needs secret-tool from libsecret")
+	}
+
+	o, _ := newTestCertOptions(t)
+	o.store = storeKeychain
+	if err := o.Validate(); err == nil || !strings.Contains(err.Error(), "secret-tool") {
+		t.Errorf("got %v, want the keychain probe to fail", err)
+	}
+}
+
+func TestSecurityQuote(t *testing.T) {
+	var tests = []struct {
+		account string
+		want    string
+	}{
+		{account: "alice@local", want: `"alice@local"`},
+		{account: `a "b" c`, want: `"a \"b\" c"`},
+		{account: `a\b`, want: `"a\\b"`},
+	}
+	for _, test := range tests {
+		if got := securityQuote(test.account); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.account, got, test.want)
+		}
+	}
+}
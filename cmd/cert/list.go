@@ -0,0 +1,155 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clientset "k8s.io/client-go/kubernetes"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+)
+
+const (
+	flagLimit    = "limit"
+	flagSelector = "selector"
+
+	defaultListLimit = 500
+)
+
+type ListOptions struct {
+	clientSet clientset.Interface
+	limit     int64
+	creator   string
+	selector  string
+	output    string
+	columns   []customColumn
+
+	out io.Writer
+}
+
+func NewCmdCertList(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	o := ListOptions{
+		out: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the certificate signing requests created by kconfig.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(configFlags))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	addCreatorFlag(cmd, &o.creator)
+	cmd.Flags().StringVarP(&o.selector, flagSelector, "l", "", "label selector further restricting the csrs, such as one set by --csr-label")
+	cmd.Flags().StringVarP(&o.output, flagOutput, "o", "", "output format - default a table, or "+customColumnsPrefix+"NAME:.metadata.name,EXPIRY:.certificate.notAfter with json paths into the csr and its issued .certificate")
+	cmd.Flags().Int64Var(&o.limit, flagLimit, defaultListLimit, "number of csrs fetched per request while paging through the results")
+
+	return cmd
+}
+
+func (o *ListOptions) Complete(configFlags *genericclioptions.ConfigFlags) error {
+	restConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	o.clientSet, err = clientset.NewForConfig(restConfig)
+	return err
+}
+
+func (o *ListOptions) Validate() error {
+	if err := validateCreator(o.creator); err != nil {
+		return err
+	}
+	if _, err := labels.Parse(o.selector); err != nil {
+		return fmt.Errorf("invalid --%s: %v", flagSelector, err)
+	}
+	if o.limit <= 0 {
+		return fmt.Errorf("--%s must be positive", flagLimit)
+	}
+	switch {
+	case len(o.output) == 0:
+	case strings.HasPrefix(o.output, customColumnsPrefix):
+		columns, err := parseCustomColumns(strings.TrimPrefix(o.output, customColumnsPrefix))
+		if err != nil {
+			return fmt.Errorf("--%s: %v", flagOutput, err)
+		}
+		o.columns = columns
+	default:
+		return fmt.Errorf("--%s must be empty or %sSPEC", flagOutput, customColumnsPrefix)
+	}
+	return nil
+}
+
+func (o *ListOptions) Run() error {
+	csrs, err := listKconfigCSRs(o.clientSet, o.creator, o.selector, o.limit)
+	if err != nil {
+		return err
+	}
+	if len(o.columns) != 0 {
+		return printCustomColumns(o.out, o.columns, csrs)
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tUSERNAME\tGROUPS\tSIGNERNAME\tCONDITION")
+	for _, csr := range csrs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", csr.Name, csr.Spec.Username, strings.Join(csr.Spec.Groups, ","), csr.Spec.SignerName, csrCondition(csr))
+	}
+
+	return w.Flush()
+}
+
+// listKconfigCSRs pages through the csrs carrying the creator label, or
+// that of the default creator, and matching the selector, fetching at most limit csrs per request.
+func listKconfigCSRs(client clientset.Interface, creator string, selector string, limit int64) ([]certificatesv1.CertificateSigningRequest, error) {
+	labelSelector := creatorSelector(creator)
+	if len(selector) != 0 {
+		labelSelector += "," + selector
+	}
+	options := metav1.ListOptions{
+		LabelSelector: labelSelector,
+		Limit:         limit,
+	}
+
+	var csrs []certificatesv1.CertificateSigningRequest
+	for {
+		list, err := client.CertificatesV1().
+			CertificateSigningRequests().
+			List(context.TODO(), options)
+		if err != nil {
+			return nil, err
+		}
+		csrs = append(csrs, list.Items...)
+
+		if len(list.Continue) == 0 {
+			return csrs, nil
+		}
+		options.Continue = list.Continue
+	}
+}
+
+func csrCondition(csr certificatesv1.CertificateSigningRequest) string {
+	var conditions []string
+	for _, c := range csr.Status.Conditions {
+		conditions = append(conditions, string(c.Type))
+	}
+	if len(csr.Status.Certificate) != 0 {
+		conditions = append(conditions, "Issued")
+	}
+	if len(conditions) == 0 {
+		return "Pending"
+	}
+	return strings.Join(conditions, ",")
+}
@@ -0,0 +1,103 @@
+package cert
+
+import (
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestListKconfigCSRs(t *testing.T) {
+	labels := map[string]string{labelCreator: creatorKconfig}
+	pages := map[string]*certificatesv1.CertificateSigningRequestList{
+		"": {
+			ListMeta: metav1.ListMeta{Continue: "page-2"},
+			Items:    []certificatesv1.CertificateSigningRequest{{ObjectMeta: metav1.ObjectMeta{Name: "alice:dev", Labels: labels}}},
+		},
+		"page-2": {
+			Items: []certificatesv1.CertificateSigningRequest{{ObjectMeta: metav1.ObjectMeta{Name: "bob:ops", Labels: labels}}},
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		restrictions := action.(k8stesting.ListAction).GetListRestrictions()
+		if restrictions.Labels.String() != labelCreator+"="+creatorKconfig {
+			t.Errorf("label selector: got %q", restrictions.Labels)
+		}
+		// the fake clientset does not pass the continue token through, so
+		// serve the pages in order
+		if len(pages) == 2 {
+			page := pages[""]
+			delete(pages, "")
+			return true, page, nil
+		}
+		return true, pages["page-2"], nil
+	})
+
+	csrs, err := listKconfigCSRs(client, creatorKconfig, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(csrs) != 2 || csrs[0].Name != "alice:dev" || csrs[1].Name != "bob:ops" {
+		t.Errorf("unexpected csrs %v", csrs)
+	}
+}
+
+func TestCreatorSelector(t *testing.T) {
+	var tests = []struct {
+		creator string
+		want    string
+	}{
+		{creator: creatorKconfig, want: labelCreator + "=" + creatorKconfig},
+		{creator: "team-a.example.com", want: labelCreator + " in (" + creatorKconfig + ",team-a.example.com)"},
+	}
+	for _, test := range tests {
+		selector, err := labels.Parse(creatorSelector(test.creator))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := selector.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.creator, got, test.want)
+		}
+	}
+}
+
+func TestRunCSRLabels(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.csrLabels = map[string]string{"team": "a"}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range client.Actions() {
+		if create, ok := action.(k8stesting.CreateAction); ok {
+			csr := create.GetObject().(*certificatesv1.CertificateSigningRequest)
+			if csr.Labels[labelCreator] != creatorKconfig || csr.Labels[labelCreatedBy] != createdByKconfig || csr.Labels["team"] != "a" {
+				t.Errorf("creator label: got %q", csr.Labels)
+			}
+		}
+	}
+}
+
+func TestValidateCSRLabels(t *testing.T) {
+	var tests = []struct {
+		labels map[string]string
+		err    bool
+	}{
+		{labels: map[string]string{"team": "a", "example.com/tier": "gold"}},
+		{labels: map[string]string{labelCreatedBy: "other"}, err: true},
+		{labels: map[string]string{"team a": "a"}, err: true},
+		{labels: map[string]string{"team": "a b"}, err: true},
+	}
+	for _, test := range tests {
+		err := validateCSRLabels(test.labels)
+		if test.err != (err != nil) {
+			t.Errorf("%v: unexpected error %v", test.labels, err)
+		}
+	}
+}
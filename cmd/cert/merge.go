@@ -0,0 +1,120 @@
+package cert
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	flagPrintMerged = "print-merged"
+
+	onConflictError     = "error"
+	onConflictSkip      = "skip"
+	onConflictOverwrite = "overwrite"
+)
+
+func validateOnConflict(onConflict string) error {
+	switch onConflict {
+	case onConflictError, onConflictSkip, onConflictOverwrite:
+		return nil
+	}
+	return fmt.Errorf("--%s must be '%s', '%s' or '%s'", flagOnConflict, onConflictError, onConflictSkip, onConflictOverwrite)
+}
+
+// sameEntry reports whether two kubeconfig entries are identical, ignoring
+// the file they were loaded from.
+func sameEntry(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *clientcmdapi.Cluster:
+		x, y := *a, *b.(*clientcmdapi.Cluster)
+		x.LocationOfOrigin, y.LocationOfOrigin = "", ""
+		return reflect.DeepEqual(x, y)
+	case *clientcmdapi.AuthInfo:
+		x, y := *a, *b.(*clientcmdapi.AuthInfo)
+		x.LocationOfOrigin, y.LocationOfOrigin = "", ""
+		return reflect.DeepEqual(x, y)
+	case *clientcmdapi.Context:
+		x, y := *a, *b.(*clientcmdapi.Context)
+		x.LocationOfOrigin, y.LocationOfOrigin = "", ""
+		return reflect.DeepEqual(x, y)
+	}
+	return false
+}
+
+// mergeKubeconfig merges the clusters, users and contexts of src into dst,
+// resolving name collisions according to onConflict. The current context
+// of dst is left untouched.
+func mergeKubeconfig(dst *clientcmdapi.Config, src *clientcmdapi.Config, onConflict string) error {
+	for name, cluster := range src.Clusters {
+		if existing, ok := dst.Clusters[name]; ok && !sameEntry(existing, cluster) {
+			if onConflict == onConflictError {
+				return fmt.Errorf("cluster %q already exists in the kubeconfig", name)
+			}
+			if onConflict == onConflictSkip {
+				continue
+			}
+		}
+		dst.Clusters[name] = cluster
+	}
+
+	for name, authInfo := range src.AuthInfos {
+		if existing, ok := dst.AuthInfos[name]; ok && !sameEntry(existing, authInfo) {
+			if onConflict == onConflictError {
+				return fmt.Errorf("user %q already exists in the kubeconfig", name)
+			}
+			if onConflict == onConflictSkip {
+				continue
+			}
+		}
+		dst.AuthInfos[name] = authInfo
+	}
+
+	for name, context := range src.Contexts {
+		if existing, ok := dst.Contexts[name]; ok && !sameEntry(existing, context) {
+			if onConflict == onConflictError {
+				return fmt.Errorf("context %q already exists in the kubeconfig", name)
+			}
+			if onConflict == onConflictSkip {
+				continue
+			}
+		}
+		dst.Contexts[name] = context
+	}
+
+	return nil
+}
+
+// redactedBytes reads REDACTED once base64 encoded in the kubeconfig, as in
+// kubectl config view.
+var redactedBytes, _ = base64.StdEncoding.DecodeString("REDACTED")
+
+// redactSecrets returns a copy of the config without the private keys,
+// tokens and passwords of its users.
+func redactSecrets(config *clientcmdapi.Config) *clientcmdapi.Config {
+	redacted := config.DeepCopy()
+	for _, authInfo := range redacted.AuthInfos {
+		if len(authInfo.ClientKeyData) != 0 {
+			authInfo.ClientKeyData = redactedBytes
+		}
+		if len(authInfo.Token) != 0 {
+			authInfo.Token = "REDACTED"
+		}
+		if len(authInfo.Password) != 0 {
+			authInfo.Password = "REDACTED"
+		}
+	}
+	return redacted
+}
+
+// printMerged prints the merged kubeconfig for review, its secrets redacted.
+func (o *CertOptions) printMerged(config *clientcmdapi.Config) error {
+	content, err := encodeKubeconfig(*redactSecrets(config), o.configVersion)
+	if err != nil {
+		return err
+	}
+	_, err = o.out.Write(content)
+	return err
+}
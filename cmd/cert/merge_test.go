@@ -0,0 +1,161 @@
+package cert
+
+import (
+	"bytes"
+	"testing"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestMergeKubeconfig(t *testing.T) {
+	var tests = []struct {
+		onConflict string
+		err        bool
+		token      string
+		current    string
+	}{
+		{onConflict: onConflictError, err: true},
+		{onConflict: onConflictSkip, token: "existing"},
+		{onConflict: onConflictOverwrite},
+	}
+	for _, test := range tests {
+		dst := clientcmdapi.Config{
+			Clusters: map[string]*clientcmdapi.Cluster{
+				"local": {Server: "https://127.0.0.1:6443"},
+			},
+			AuthInfos: map[string]*clientcmdapi.AuthInfo{
+				"admin": {Token: "admin"},
+				"alice": {Token: "existing"},
+			},
+			Contexts: map[string]*clientcmdapi.Context{
+				"admin@local": {Cluster: "local", AuthInfo: "admin"},
+				"alice@local": {Cluster: "local", AuthInfo: "alice"},
+			},
+			CurrentContext: "admin@local",
+		}
+		src := newKubeconfig("local", &clientcmdapi.Cluster{Server: "https://127.0.0.1:6443"}, "alice", "default", []byte("key"), []byte("cert"))
+
+		err := mergeKubeconfig(&dst, &src, test.onConflict)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected a conflict error", test.onConflict)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.onConflict, err)
+			continue
+		}
+
+		if dst.AuthInfos["alice"].Token != test.token {
+			t.Errorf("%s: token: got %q, want %q", test.onConflict, dst.AuthInfos["alice"].Token, test.token)
+		}
+
+		if dst.CurrentContext != "admin@local" {
+			t.Errorf("%s: current context: got %q, want %q", test.onConflict, dst.CurrentContext, "admin@local")
+		}
+
+		if len(dst.AuthInfos) != 2 || len(dst.Contexts) != 2 || len(dst.Clusters) != 1 {
+			t.Errorf("%s: unexpected entries after merge: %d users, %d contexts, %d clusters", test.onConflict, len(dst.AuthInfos), len(dst.Contexts), len(dst.Clusters))
+		}
+	}
+}
+
+func TestRunMerge(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	o.merge = true
+	o.output = ""
+
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := o.configAccess.GetStartingConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := config.Contexts["alice@local"]; !ok {
+		t.Error("merged context alice@local not found")
+	}
+
+	if string(config.AuthInfos["alice"].ClientCertificateData) != "certificate" {
+		t.Errorf("merged user alice: got certificate %q", config.AuthInfos["alice"].ClientCertificateData)
+	}
+}
+
+func TestRunMergeClusterName(t *testing.T) {
+	var tests = []struct {
+		clusterName string
+	}{
+		{clusterName: "renamed"},
+		{clusterName: "local"},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.merge = true
+		o.output = ""
+		o.clusterName = test.clusterName
+
+		if err := o.Run(); err != nil {
+			t.Fatal(err)
+		}
+
+		config, err := o.configAccess.GetStartingConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		context, ok := config.Contexts["alice@"+test.clusterName]
+		if !ok {
+			t.Errorf("%s: merged context alice@%s not found", test.clusterName, test.clusterName)
+			continue
+		}
+		if context.Cluster != test.clusterName {
+			t.Errorf("%s: context cluster: got %q", test.clusterName, context.Cluster)
+		}
+		if _, ok := config.Clusters[test.clusterName]; !ok {
+			t.Errorf("%s: cluster not found", test.clusterName)
+		}
+	}
+}
+
+func TestRunPrintMerged(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	o.merge = true
+	o.output = ""
+	o.printMergedConfig = true
+	var out bytes.Buffer
+	o.out = &out
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	printed, err := clientcmd.Load(out.Bytes())
+	if err != nil {
+		t.Fatalf("%v: %q", err, out.String())
+	}
+	if _, ok := printed.Contexts["alice@local"]; !ok || printed.CurrentContext != "admin@local" {
+		t.Errorf("printed config is not the merged one: %q", out.String())
+	}
+	alice, admin := printed.AuthInfos["alice"], printed.AuthInfos["admin"]
+	if string(alice.ClientCertificateData) != "certificate" {
+		t.Errorf("certificate: got %q", alice.ClientCertificateData)
+	}
+	if !bytes.Equal(alice.ClientKeyData, redactedBytes) || admin.Token != "REDACTED" {
+		t.Errorf("secrets printed: %q", out.String())
+	}
+
+	// the written kubeconfig keeps them
+	config, err := o.configAccess.GetStartingConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(config.AuthInfos["alice"].ClientKeyData, redactedBytes) || config.AuthInfos["admin"].Token != "token" {
+		t.Error("secrets redacted in the written kubeconfig")
+	}
+}
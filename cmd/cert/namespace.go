@@ -0,0 +1,50 @@
+package cert
+
+import (
+	"context"
+	"sort"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// inferNamespace returns the namespace of a rolebinding for the user,
+// falling back to one for any of its groups and then to DefaultNamespace.
+// Ties are broken by the namespace name.
+func inferNamespace(client clientset.Interface, userName string, groups []string) string {
+	var userNamespaces, groupNamespaces []string
+	options := metav1.ListOptions{Limit: defaultListLimit}
+	for {
+		list, err := client.RbacV1().RoleBindings(metav1.NamespaceAll).List(context.TODO(), options)
+		if err != nil {
+			klog.Warningf("unable to infer the namespace from rolebindings, using %q: %v", DefaultNamespace, err)
+			return DefaultNamespace
+		}
+		for _, binding := range list.Items {
+			for _, subject := range binding.Subjects {
+				switch {
+				case subject.Kind == rbacv1.UserKind && subject.Name == userName:
+					userNamespaces = append(userNamespaces, binding.Namespace)
+				case subject.Kind == rbacv1.GroupKind && contains(groups, subject.Name):
+					groupNamespaces = append(groupNamespaces, binding.Namespace)
+				}
+			}
+		}
+
+		if len(list.Continue) == 0 {
+			break
+		}
+		options.Continue = list.Continue
+	}
+
+	for _, namespaces := range [][]string{userNamespaces, groupNamespaces} {
+		if len(namespaces) != 0 {
+			sort.Strings(namespaces)
+			klog.V(2).InfoS("inferred namespace", "namespace", namespaces[0])
+			return namespaces[0]
+		}
+	}
+	return DefaultNamespace
+}
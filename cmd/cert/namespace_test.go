@@ -0,0 +1,86 @@
+package cert
+
+import (
+	"errors"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newRoleBinding(namespace string, kind string, name string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Subjects:   []rbacv1.Subject{{Kind: kind, Name: name}},
+	}
+}
+
+func TestInferNamespace(t *testing.T) {
+	var tests = []struct {
+		name      string
+		bindings  []runtime.Object
+		forbidden bool
+		want      string
+	}{
+		{name: "none", want: DefaultNamespace},
+		{
+			name: "user over group",
+			bindings: []runtime.Object{
+				newRoleBinding("apps", rbacv1.GroupKind, "dev"),
+				newRoleBinding("team-alice", rbacv1.UserKind, "alice"),
+				newRoleBinding("other", rbacv1.UserKind, "bob"),
+			},
+			want: "team-alice",
+		},
+		{
+			name: "group",
+			bindings: []runtime.Object{
+				newRoleBinding("web", rbacv1.GroupKind, "dev"),
+				newRoleBinding("apps", rbacv1.GroupKind, "dev"),
+			},
+			want: "apps",
+		},
+		{name: "forbidden", forbidden: true, want: DefaultNamespace},
+	}
+	for _, test := range tests {
+		client := fake.NewSimpleClientset(test.bindings...)
+		if test.forbidden {
+			client.PrependReactor("list", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, apierrors.NewForbidden(rbacv1.Resource("rolebindings"), "", errors.New("no access"))
+			})
+		}
+
+		if got := inferNamespace(client, "alice", []string{"dev"}); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestInferNamespacePages(t *testing.T) {
+	pages := []*rbacv1.RoleBindingList{
+		{
+			ListMeta: metav1.ListMeta{Continue: "page-2"},
+			Items:    []rbacv1.RoleBinding{*newRoleBinding("apps", rbacv1.GroupKind, "dev")},
+		},
+		{
+			Items: []rbacv1.RoleBinding{*newRoleBinding("team-alice", rbacv1.UserKind, "alice")},
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "rolebindings", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		// the fake clientset does not pass the continue token through, so
+		// serve the pages in order
+		page := pages[0]
+		pages = pages[1:]
+		return true, page, nil
+	})
+
+	if got := inferNamespace(client, "alice", []string{"dev"}); got != "team-alice" {
+		t.Errorf("got %q, want the user binding of the second page", got)
+	}
+}
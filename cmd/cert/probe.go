@@ -0,0 +1,218 @@
+package cert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+)
+
+const (
+	flagDiscoveryTimeout = "discovery-timeout"
+
+	defaultDiscoveryTimeout = 5 * time.Second
+)
+
+type ProbeOptions struct {
+	signerName       string
+	discoveryTimeout time.Duration
+
+	clientSet clientset.Interface
+	discovery discovery.DiscoveryInterface
+
+	out io.Writer
+}
+
+// probeCheck is one line of the readiness report.
+type probeCheck struct {
+	name   string
+	result string
+	ok     bool
+}
+
+func NewCmdCertProbe(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	o := ProbeOptions{
+		out: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "probe",
+		Short: "Check that the cluster serves certificates.k8s.io/v1 and the current user may issue and approve csrs, without creating one.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(configFlags))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.signerName, flagSignerName, certificatesv1.KubeAPIServerClientSignerName, "signer name the csrs would be approved for")
+	addDiscoveryTimeoutFlag(cmd, &o.discoveryTimeout)
+
+	return cmd
+}
+
+func addDiscoveryTimeoutFlag(cmd *cobra.Command, timeout *time.Duration) {
+	cmd.Flags().DurationVar(timeout, flagDiscoveryTimeout, defaultDiscoveryTimeout, "time to wait for api discovery, such as listing the signers in use, before failing - 0 waits as long as the request takes")
+}
+
+// isTimeout reports whether err is a request that got no response within
+// its timeout or context deadline.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (o *ProbeOptions) Complete(configFlags *genericclioptions.ConfigFlags) error {
+	restConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	o.clientSet, err = clientset.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	o.discovery, err = newDiscoveryClient(restConfig, o.discoveryTimeout)
+	return err
+}
+
+// newDiscoveryClient returns a discovery client whose requests fail once
+// timeout passes, e.g. against an unresponsive apiserver.
+func newDiscoveryClient(restConfig *rest.Config, timeout time.Duration) (discovery.DiscoveryInterface, error) {
+	config := rest.CopyConfig(restConfig)
+	config.Timeout = timeout
+	return discovery.NewDiscoveryClientForConfig(config)
+}
+
+func (o *ProbeOptions) Validate() error {
+	if o.discoveryTimeout < 0 {
+		return fmt.Errorf("--%s must not be negative", flagDiscoveryTimeout)
+	}
+	return nil
+}
+
+// Run prints the readiness report and fails if any check did not pass.
+func (o *ProbeOptions) Run() error {
+	check, err := o.probeAPI()
+	if err != nil {
+		return err
+	}
+	checks := []probeCheck{check}
+
+	for _, attributes := range []authorizationv1.ResourceAttributes{
+		{Verb: "create", Resource: "certificatesigningrequests"},
+		{Verb: "get", Resource: "certificatesigningrequests"},
+		{Verb: "delete", Resource: "certificatesigningrequests"},
+		{Verb: "update", Resource: "certificatesigningrequests", Subresource: "approval"},
+		{Verb: "approve", Resource: "signers", Name: o.signerName},
+	} {
+		check, err := o.probeAccess(attributes)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, check)
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT")
+	failed := 0
+	for _, c := range checks {
+		fmt.Fprintf(w, "%s\t%s\n", c.name, c.result)
+		if !c.ok {
+			failed++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("not ready, %d of %d checks failed", failed, len(checks))
+	}
+	fmt.Fprintln(o.out, "Ready.")
+	return nil
+}
+
+// probeAPI looks up the served versions of certificates.k8s.io, kconfig
+// requires v1.
+func (o *ProbeOptions) probeAPI() (probeCheck, error) {
+	check := probeCheck{name: certificatesv1.GroupName}
+
+	client := o.discovery
+	if client == nil {
+		client = o.clientSet.Discovery()
+	}
+	groups, err := client.ServerGroups()
+	if isTimeout(err) {
+		return check, fmt.Errorf("discovery: no response within --%s %s, the apiserver may be unresponsive", flagDiscoveryTimeout, o.discoveryTimeout)
+	}
+	if err != nil {
+		return check, fmt.Errorf("discovery: %v", err)
+	}
+	for _, group := range groups.Groups {
+		if group.Name != certificatesv1.GroupName {
+			continue
+		}
+		var versions []string
+		for _, version := range group.Versions {
+			versions = append(versions, version.Version)
+			if version.Version == certificatesv1.SchemeGroupVersion.Version {
+				check.ok = true
+			}
+		}
+		check.result = fmt.Sprintf("served %s, preferred %s", strings.Join(versions, ","), group.PreferredVersion.Version)
+		if !check.ok {
+			check.result += ", v1 required"
+		}
+		return check, nil
+	}
+
+	check.result = "not served"
+	return check, nil
+}
+
+// probeAccess asks the apiserver whether the current user may act on the
+// certificates.k8s.io resource.
+func (o *ProbeOptions) probeAccess(attributes authorizationv1.ResourceAttributes) (probeCheck, error) {
+	attributes.Group = certificatesv1.GroupName
+
+	name := attributes.Verb + " " + attributes.Resource
+	if len(attributes.Subresource) != 0 {
+		name += "/" + attributes.Subresource
+	}
+	if len(attributes.Name) != 0 {
+		name += " " + attributes.Name
+	}
+
+	review, err := o.clientSet.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return probeCheck{}, fmt.Errorf("%s: %v", name, err)
+	}
+
+	check := probeCheck{name: name, ok: review.Status.Allowed, result: "allowed"}
+	if !review.Status.Allowed {
+		check.result = "denied"
+		if len(review.Status.Reason) != 0 {
+			check.result += " - " + review.Status.Reason
+		}
+	}
+	return check, nil
+}
@@ -0,0 +1,90 @@
+package cert
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestRunProbe(t *testing.T) {
+	var tests = []struct {
+		name    string
+		version string
+		denied  string
+		err     bool
+		want    string
+	}{
+		{name: "ready", version: "v1", want: "Ready."},
+		{name: "v1beta1", version: "v1beta1", err: true, want: "v1 required"},
+		{name: "not served", err: true, want: "not served"},
+		{name: "denied", version: "v1", denied: "approve", err: true, want: "approve signers kubernetes.io/kube-apiserver-client   denied"},
+	}
+	for _, test := range tests {
+		client := fake.NewSimpleClientset()
+		if len(test.version) != 0 {
+			client.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+				{GroupVersion: "certificates.k8s.io/" + test.version},
+			}
+		}
+		client.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+			review.Status.Allowed = review.Spec.ResourceAttributes.Verb != test.denied
+			return true, review, nil
+		})
+
+		var out bytes.Buffer
+		o := ProbeOptions{signerName: "kubernetes.io/kube-apiserver-client", clientSet: client, out: &out}
+		err := o.Run()
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if !strings.Contains(out.String(), test.want) {
+			t.Errorf("%s: %q not in %q", test.name, test.want, out.String())
+		}
+		for _, action := range client.Actions() {
+			if action.GetResource().Resource == "certificatesigningrequests" {
+				t.Errorf("%s: unexpected %s of a csr", test.name, action.GetVerb())
+			}
+		}
+	}
+}
+
+func TestRunProbeDiscoveryTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	var out bytes.Buffer
+	o := ProbeOptions{clientSet: fake.NewSimpleClientset(), discoveryTimeout: 10 * time.Millisecond, out: &out}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	o.discovery, err = newDiscoveryClient(&rest.Config{Host: server.URL}, o.discoveryTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = o.Run()
+	if err == nil || !strings.Contains(err.Error(), "--discovery-timeout 10ms") {
+		t.Errorf("got error %v, want a discovery timeout", err)
+	}
+
+	o.discoveryTimeout = -time.Second
+	if err := o.Validate(); err == nil {
+		t.Error("accepted a negative --discovery-timeout")
+	}
+}
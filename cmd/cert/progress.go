@@ -0,0 +1,54 @@
+package cert
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const flagQuiet = "quiet"
+
+var (
+	progressInterval = 100 * time.Millisecond
+
+	// progressTerminal reports whether the progress writer is a terminal,
+	// swapped by tests.
+	progressTerminal = func(w io.Writer) bool {
+		f, ok := w.(*os.File)
+		return ok && isTerminal(f)
+	}
+)
+
+// startProgress shows a spinner with the phase and the elapsed time on
+// stderr until the returned func is called. It shows nothing with --quiet
+// or when stderr is not a terminal, keeping logs and pipes clean.
+func (o *CertOptions) startProgress(phase string) func() {
+	if o.quiet || !progressTerminal(o.errOut) {
+		return func() {}
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for frame := 0; ; frame++ {
+			elapsed := time.Since(o.start).Round(time.Second)
+			fmt.Fprintf(o.errOut, "\r%c %s %s", `|/-\`[frame%4], phase, elapsed)
+			select {
+			case <-stop:
+				// clear the line for whatever is printed next
+				fmt.Fprint(o.errOut, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
@@ -0,0 +1,47 @@
+package cert
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartProgress(t *testing.T) {
+	defer func(interval time.Duration, terminal func(io.Writer) bool) {
+		progressInterval, progressTerminal = interval, terminal
+	}(progressInterval, progressTerminal)
+	progressInterval = time.Millisecond
+
+	var tests = []struct {
+		name     string
+		terminal bool
+		quiet    bool
+		want     bool
+	}{
+		{name: "terminal", terminal: true, want: true},
+		{name: "quiet", terminal: true, quiet: true},
+		{name: "not a terminal"},
+	}
+	for _, test := range tests {
+		progressTerminal = func(io.Writer) bool { return test.terminal }
+
+		var errOut bytes.Buffer
+		o := CertOptions{errOut: &errOut, quiet: test.quiet, start: time.Now()}
+		stop := o.startProgress("waiting")
+		time.Sleep(5 * time.Millisecond)
+		stop()
+
+		got := errOut.String()
+		if !test.want {
+			if len(got) != 0 {
+				t.Errorf("%s: got progress %q, want none", test.name, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, "waiting 0s") || !strings.HasSuffix(got, "\r\033[K") {
+			t.Errorf("%s: got progress %q", test.name, got)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package cert
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog/v2"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const (
+	flagRenewBefore = "renew-before"
+	flagRotateKey   = "rotate-key"
+	flagAddGroup    = "add-group"
+
+	defaultRenewBefore = 720 * time.Hour
+)
+
+type RefreshOptions struct {
+	cert        *CertOptions
+	renewBefore time.Duration
+	rotateKey   bool
+	addGroups   []string
+
+	authInfoName string
+	configFile   string
+	current      *x509.Certificate
+	granted      []string
+}
+
+func NewCmdCertRefresh(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	o := RefreshOptions{cert: newCertOptions()}
+	// renewing an identity already held, such as the system:masters admin
+	// of kubeadm, grants nothing new
+	o.cert.allowSystemIdentities = true
+	// and a certificate without organizations is renewed as is
+	o.cert.allowNoGroups = true
+
+	cmd := &cobra.Command{
+		Use:   "refresh",
+		Short: "Renew the client certificate of the current context in place.",
+		Run: func(cmd *cobra.Command, args []string) {
+			o.cert.deleteOnSuccessSet = cmd.Flags().Changed(flagDeleteOnSuccess)
+			o.cert.curveSet = cmd.Flags().Changed(flagCurve)
+			cmdutil.CheckErr(o.Complete(configFlags))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	cmd.Flags().StringVar(&o.cert.keyType, flagKeyType, o.cert.keyType, "private key type - one of 'rsa' or 'ecdsa'")
+	cmd.Flags().StringVar(&o.cert.curve, flagCurve, o.cert.curve, "named curve for ecdsa keys - one of 'P-256', 'P-384' or 'P-521'")
+	cmd.Flags().DurationVar(&o.renewBefore, flagRenewBefore, o.cert.renewBefore, "only renew certificates expiring within this duration")
+	cmd.Flags().BoolVar(&o.rotateKey, flagRotateKey, false, "renew with a new key regardless of --"+flagRenewBefore)
+	cmd.Flags().StringSliceVar(&o.addGroups, flagAddGroup, nil, "groups to add to the groups of the current certificate, renewing regardless of --"+flagRenewBefore)
+	addDeleteOnSuccessFlag(cmd, &o.cert.deleteOnSuccess)
+	cmd.Flags().DurationVar(&o.cert.timeout, flagTimeout, 0, "how long to wait for the csr to be issued - zero means wait forever")
+
+	return cmd
+}
+
+// Complete takes the user name and groups from the subject of the current
+// context's client certificate, adding the groups of --add-group.
+func (o *RefreshOptions) Complete(configFlags *genericclioptions.ConfigFlags) error {
+	if configFlags != nil {
+		o.cert.configAccess = configFlags.ToRawKubeConfigLoader().ConfigAccess()
+	}
+	if err := o.completeIdentity(); err != nil {
+		return err
+	}
+	return o.cert.Complete(configFlags)
+}
+
+// completeIdentity reads the current certificate and the identity to renew
+// from the kubeconfig.
+func (o *RefreshOptions) completeIdentity() error {
+	config, err := loadStartingConfig(o.cert.configAccess)
+	if err != nil {
+		return err
+	}
+
+	ctx, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return fmt.Errorf("current context %q not found in kubeconfig", config.CurrentContext)
+	}
+	authInfo, ok := config.AuthInfos[ctx.AuthInfo]
+	if !ok {
+		return fmt.Errorf("user %q of context %q not found in kubeconfig", ctx.AuthInfo, config.CurrentContext)
+	}
+	o.authInfoName = ctx.AuthInfo
+	o.configFile = authInfo.LocationOfOrigin
+
+	o.current, err = clientCertificate(authInfo)
+	if err != nil {
+		return fmt.Errorf("user %q of context %q: %v", ctx.AuthInfo, config.CurrentContext, err)
+	}
+	o.cert.userName, o.cert.groups, err = certificateIdentity(o.current)
+	if err != nil {
+		return fmt.Errorf("user %q of context %q: %v", ctx.AuthInfo, config.CurrentContext, err)
+	}
+	o.cert.groups, o.granted = unionGroups(o.cert.groups, o.addGroups)
+	return nil
+}
+
+func (o *RefreshOptions) Validate() error {
+	if len(o.configFile) == 0 {
+		return fmt.Errorf("unable to tell which kubeconfig file defines user %q", o.authInfoName)
+	}
+	if o.renewBefore < 0 {
+		return fmt.Errorf("--%s must not be negative", flagRenewBefore)
+	}
+	for _, group := range o.addGroups {
+		if len(strings.TrimSpace(group)) == 0 {
+			return fmt.Errorf("--%s must not be empty", flagAddGroup)
+		}
+		// refresh allows the system identities already held, not new ones
+		if strings.HasPrefix(group, systemIdentityPrefix) {
+			return fmt.Errorf("--%s %q is reserved for kubernetes components", flagAddGroup, group)
+		}
+	}
+	return o.cert.Validate()
+}
+
+// Run issues a new certificate for a new key and only then replaces the key
+// and certificate of the user, keeping the current ones if issuance fails.
+func (o *RefreshOptions) Run() error {
+	o.cert.start = time.Now()
+
+	if !o.rotateKey && len(o.granted) == 0 && !needsRenewal(o.current, o.renewBefore) {
+		fmt.Fprintf(o.cert.errOut, "Certificate of %q is valid until %s, not renewing before --%s %s.\n", o.authInfoName, o.current.NotAfter.UTC().Format(time.RFC3339), flagRenewBefore, o.renewBefore)
+		return nil
+	}
+
+	if len(o.granted) != 0 {
+		fmt.Fprintf(o.cert.errOut, "Adding groups %q to %q.\n", o.granted, o.authInfoName)
+	}
+
+	issuer := o.cert.issuer()
+	defer deleteOnPanic(issuer)
+	key, cert, err := issuer.issue()
+	if err != nil {
+		return err
+	}
+
+	err = o.updateAuthInfo(key, cert)
+	if err != nil {
+		return err
+	}
+
+	return o.cert.deleteIssued(issuer)
+}
+
+func (o *RefreshOptions) updateAuthInfo(key []byte, cert []byte) error {
+	fi, err := os.Stat(o.configFile)
+	if err != nil {
+		return err
+	}
+	config, err := clientcmd.LoadFromFile(o.configFile)
+	if err != nil {
+		return err
+	}
+
+	authInfo, ok := config.AuthInfos[o.authInfoName]
+	if !ok {
+		return fmt.Errorf("user %q not found in kubeconfig %s", o.authInfoName, o.configFile)
+	}
+	authInfo.ClientKey = ""
+	authInfo.ClientKeyData = key
+	authInfo.ClientCertificate = ""
+	authInfo.ClientCertificateData = cert
+
+	content, err := clientcmd.Write(*config)
+	if err != nil {
+		return err
+	}
+
+	return cmdutil.WriteFileAtomic(o.configFile, content, fi.Mode().Perm())
+}
+
+// clientCertificate parses the first client certificate of the user.
+func clientCertificate(authInfo *clientcmdapi.AuthInfo) (*x509.Certificate, error) {
+	data := authInfo.ClientCertificateData
+	if len(data) == 0 {
+		if len(authInfo.ClientCertificate) == 0 {
+			return nil, fmt.Errorf("no client certificate")
+		}
+		var err error
+		data, err = os.ReadFile(authInfo.ClientCertificate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	certs, err := cmdutilpkix.ParseCertificatesPem(data)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// certificateIdentity returns the common name and organizations of the
+// client certificate.
+func certificateIdentity(cert *x509.Certificate) (string, []string, error) {
+	if len(strings.TrimSpace(cert.Subject.CommonName)) == 0 {
+		return "", nil, fmt.Errorf("client certificate has no common name")
+	}
+	return cert.Subject.CommonName, cert.Subject.Organization, nil
+}
+
+// unionGroups appends the added groups missing from groups, returning the
+// union and the groups actually added.
+func unionGroups(groups []string, added []string) ([]string, []string) {
+	union := append([]string{}, groups...)
+	var granted []string
+	for _, group := range added {
+		if !contains(union, group) {
+			union = append(union, group)
+			granted = append(granted, group)
+		}
+	}
+	return union, granted
+}
+
+// needsRenewal reports whether the certificate expires within threshold or
+// has already expired.
+func needsRenewal(cert *x509.Certificate, threshold time.Duration) bool {
+	return time.Now().After(renewalStart(cert, threshold))
+}
+
+// renewalStart returns when the certificate starts to expire within
+// threshold. Certificates valid for less than threshold, which would need
+// renewal right away, start to expire after two thirds of their validity.
+func renewalStart(cert *x509.Certificate, threshold time.Duration) time.Time {
+	validity := cert.NotAfter.Sub(cert.NotBefore)
+	if threshold >= validity {
+		klog.V(2).InfoS("certificate valid for less than --"+flagRenewBefore+", renewing after two thirds of it", "validity", validity, "renewBefore", threshold)
+		return cert.NotBefore.Add(validity * 2 / 3)
+	}
+	return cert.NotAfter.Add(-threshold)
+}
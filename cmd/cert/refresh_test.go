@@ -0,0 +1,174 @@
+package cert
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+// newTestRefreshOptions refreshes alice of the dev and ops groups from a
+// kubeconfig file, returned as the path.
+func newTestRefreshOptions(t *testing.T) (*RefreshOptions, *fake.Clientset, string) {
+	t.Helper()
+
+	_, der, err := cmdutilpkix.CreateSelfSignedCertificate("alice", []string{"dev", "ops"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := cmdutilpkix.PemCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config")
+	err = clientcmd.WriteToFile(clientcmdapi.Config{
+		Clusters:       map[string]*clientcmdapi.Cluster{"local": {Server: "https://127.0.0.1:6443"}},
+		AuthInfos:      map[string]*clientcmdapi.AuthInfo{"alice": {ClientCertificateData: cert, ClientKeyData: []byte("key")}},
+		Contexts:       map[string]*clientcmdapi.Context{"alice@local": {Cluster: "local", AuthInfo: "alice"}},
+		CurrentContext: "alice@local",
+	}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, client := newTestCertOptions(t)
+	c.configAccess = &clientcmd.PathOptions{
+		GlobalFile:   path,
+		LoadingRules: &clientcmd.ClientConfigLoadingRules{ExplicitPath: path},
+	}
+	o := RefreshOptions{cert: c}
+	if err := o.completeIdentity(); err != nil {
+		t.Fatal(err)
+	}
+	return &o, client, path
+}
+
+func TestRefresh(t *testing.T) {
+	o, client, path := newTestRefreshOptions(t)
+	c := o.cert
+	if c.userName != "alice" || !reflect.DeepEqual(c.groups, []string{"dev", "ops"}) {
+		t.Fatalf("identity: got %q %q", c.userName, c.groups)
+	}
+
+	o.renewBefore = time.Hour
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countActions(client, "create"); n != 0 {
+		t.Fatalf("renewed a fresh certificate")
+	}
+
+	o.rotateKey = true
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authInfo := refreshed.AuthInfos["alice"]
+	if string(authInfo.ClientCertificateData) != "certificate" {
+		t.Errorf("certificate not replaced: %q", authInfo.ClientCertificateData)
+	}
+	if string(authInfo.ClientKeyData) == "key" {
+		t.Error("key not replaced")
+	}
+	rotated := string(authInfo.ClientKeyData)
+
+	o.rotateKey = false
+	o.renewBefore = 48 * time.Hour
+	expiring, err := cmdutilpkix.ParseCertificatesPem(testCertificate(t, time.Now().Add(-365*24*time.Hour), 366*24*time.Hour, "dev", "ops"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.current = expiring[0]
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err = clientcmd.LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(refreshed.AuthInfos["alice"].ClientKeyData) == rotated {
+		t.Error("renewal kept the key")
+	}
+	if n := countActions(client, "delete"); n != 2 {
+		t.Errorf("deletes: got %d, want 2", n)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("mode of refreshed kubeconfig: %v %v", fi.Mode(), err)
+	}
+}
+
+func TestRefreshAddGroup(t *testing.T) {
+	o, client, _ := newTestRefreshOptions(t)
+	o.addGroups = []string{"ops", "admin"}
+	if err := o.completeIdentity(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(o.cert.groups, []string{"dev", "ops", "admin"}) || !reflect.DeepEqual(o.granted, []string{"admin"}) {
+		t.Fatalf("got groups %q and granted %q, want [dev ops admin] and [admin]", o.cert.groups, o.granted)
+	}
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// adding a group renews the fresh certificate
+	o.renewBefore = time.Hour
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+	var request []byte
+	for _, action := range client.Actions() {
+		if action.Matches("create", "certificatesigningrequests") {
+			request = action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest).Spec.Request
+		}
+	}
+	csr, err := cmdutilpkix.ParseCertificateRequestPem(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dev", "ops", "admin"}; !reflect.DeepEqual(csr.Subject.Organization, want) {
+		t.Errorf("groups: got %q, want %q", csr.Subject.Organization, want)
+	}
+
+	o.addGroups = []string{"system:masters"}
+	if err := o.Validate(); err == nil {
+		t.Error("added a system group")
+	}
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	now := time.Now()
+	var tests = []struct {
+		name      string
+		notBefore time.Time
+		notAfter  time.Time
+		want      bool
+	}{
+		{name: "expired", notAfter: now.Add(-time.Hour), want: true},
+		{name: "near expiry", notAfter: now.Add(24 * time.Hour), want: true},
+		{name: "fresh", notAfter: now.Add(365 * 24 * time.Hour), want: false},
+		// valid for less than the default 720h, renewed after two thirds
+		{name: "short-lived fresh", notBefore: now.Add(-time.Hour), notAfter: now.Add(2 * time.Hour), want: false},
+		{name: "short-lived two thirds", notBefore: now.Add(-3 * time.Hour), notAfter: now.Add(time.Hour), want: true},
+	}
+	for _, test := range tests {
+		cert := &x509.Certificate{NotBefore: test.notBefore, NotAfter: test.notAfter}
+		if got := needsRenewal(cert, defaultRenewBefore); got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cert
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	flagRole             = "role"
+	flagRoleGroup        = "role-group"
+	flagAllowUnknownRole = "allow-unknown-role"
+)
+
+// parseRoleGroups parses the role=group entries of --role-group into the
+// groups of each role.
+func parseRoleGroups(entries []string) (map[string][]string, error) {
+	roleGroups := map[string][]string{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return nil, fmt.Errorf("invalid --%s %q, must be role=group", flagRoleGroup, entry)
+		}
+		roleGroups[parts[0]] = append(roleGroups[parts[0]], parts[1])
+	}
+	return roleGroups, nil
+}
+
+// expandRoles appends the groups of the roles to groups, keeping the first
+// of any duplicate group.
+func expandRoles(groups []string, roles []string, roleGroups map[string][]string, allowUnknown bool) ([]string, error) {
+	expanded := append([]string{}, groups...)
+	for _, role := range roles {
+		g, ok := roleGroups[role]
+		if !ok {
+			if !allowUnknown {
+				return nil, fmt.Errorf("unknown --%s %q, map it to groups with --%s %s=group or use --%s", flagRole, role, flagRoleGroup, role, flagAllowUnknownRole)
+			}
+			klog.Warningf("ignoring unknown role %q", role)
+			continue
+		}
+		expanded = append(expanded, g...)
+	}
+
+	var deduped []string
+	for _, group := range expanded {
+		if !contains(deduped, group) {
+			deduped = append(deduped, group)
+		}
+	}
+	return deduped, nil
+}
@@ -0,0 +1,44 @@
+package cert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandRoles(t *testing.T) {
+	roleGroups, err := parseRoleGroups([]string{"editor=team:devs", "editor=cluster:editors", "viewer=cluster:viewers"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name         string
+		groups       []string
+		roles        []string
+		allowUnknown bool
+		want         []string
+		err          bool
+	}{
+		{name: "role", roles: []string{"editor"}, want: []string{"team:devs", "cluster:editors"}},
+		{name: "merged and deduped", groups: []string{"team:devs", "ops"}, roles: []string{"editor", "viewer"}, want: []string{"team:devs", "ops", "cluster:editors", "cluster:viewers"}},
+		{name: "unknown", roles: []string{"admin"}, err: true},
+		{name: "unknown allowed", groups: []string{"ops"}, roles: []string{"admin"}, allowUnknown: true, want: []string{"ops"}},
+	}
+	for _, test := range tests {
+		got, err := expandRoles(test.groups, test.roles, roleGroups, test.allowUnknown)
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseRoleGroups(t *testing.T) {
+	for _, entry := range []string{"editor", "=team:devs", "editor="} {
+		if _, err := parseRoleGroups([]string{entry}); err == nil {
+			t.Errorf("%q: expected an error", entry)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+)
+
+const customSignerDescription = "custom signer used by existing csrs"
+
+type signer struct {
+	name        string
+	description string
+	// extUsage is the only extended key usage the signer issues.
+	extUsage certificatesv1.KeyUsage
+}
+
+var builtinSigners = []signer{
+	{
+		name:        certificatesv1.KubeAPIServerClientSignerName,
+		description: "client certificates for the kube-apiserver, never auto-approved",
+		extUsage:    certificatesv1.UsageClientAuth,
+	},
+	{
+		name:        certificatesv1.KubeAPIServerClientKubeletSignerName,
+		description: "kubelet client certificates for the kube-apiserver, may be auto-approved",
+		extUsage:    certificatesv1.UsageClientAuth,
+	},
+	{
+		name:        certificatesv1.KubeletServingSignerName,
+		description: "kubelet serving certificates, never auto-approved",
+		extUsage:    certificatesv1.UsageServerAuth,
+	},
+}
+
+func isBuiltinSigner(name string) bool {
+	_, ok := builtinSigner(name)
+	return ok
+}
+
+func builtinSigner(name string) (signer, bool) {
+	for _, s := range builtinSigners {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return signer{}, false
+}
+
+var knownKeyUsages = []certificatesv1.KeyUsage{
+	certificatesv1.UsageSigning,
+	certificatesv1.UsageDigitalSignature,
+	certificatesv1.UsageContentCommitment,
+	certificatesv1.UsageKeyEncipherment,
+	certificatesv1.UsageKeyAgreement,
+	certificatesv1.UsageDataEncipherment,
+	certificatesv1.UsageCertSign,
+	certificatesv1.UsageCRLSign,
+	certificatesv1.UsageEncipherOnly,
+	certificatesv1.UsageDecipherOnly,
+	certificatesv1.UsageAny,
+	certificatesv1.UsageServerAuth,
+	certificatesv1.UsageClientAuth,
+	certificatesv1.UsageCodeSigning,
+	certificatesv1.UsageEmailProtection,
+	certificatesv1.UsageSMIME,
+	certificatesv1.UsageIPsecEndSystem,
+	certificatesv1.UsageIPsecTunnel,
+	certificatesv1.UsageIPsecUser,
+	certificatesv1.UsageTimestamping,
+	certificatesv1.UsageOCSPSigning,
+	certificatesv1.UsageMicrosoftSGC,
+	certificatesv1.UsageNetscapeSGC,
+}
+
+// keyUsages returns the csr usages.
+func keyUsages(usages []string) []certificatesv1.KeyUsage {
+	keyUsages := make([]certificatesv1.KeyUsage, 0, len(usages))
+	for _, u := range usages {
+		keyUsages = append(keyUsages, certificatesv1.KeyUsage(u))
+	}
+	return keyUsages
+}
+
+// validateSignerUsages checks that the usages include the client auth a
+// kubeconfig needs and that a built-in signer issues all of them. A single
+// csr has one signer, so client and server auth from the built-in signers
+// can't be combined.
+func validateSignerUsages(name string, usages []string) error {
+	var clientAuth bool
+	for _, u := range keyUsages(usages) {
+		if !containsKeyUsage(knownKeyUsages, u) {
+			return fmt.Errorf("--%s: unknown key usage %q", flagUsages, u)
+		}
+		clientAuth = clientAuth || u == certificatesv1.UsageClientAuth
+	}
+	if !clientAuth {
+		return fmt.Errorf("--%s must include %q to authenticate with the kubeconfig", flagUsages, certificatesv1.UsageClientAuth)
+	}
+
+	s, ok := builtinSigner(name)
+	if !ok {
+		return nil
+	}
+	if s.extUsage != certificatesv1.UsageClientAuth {
+		return fmt.Errorf("signer %q issues %q certificates only, not the %q a kubeconfig needs - use --%s %s", name, s.extUsage, certificatesv1.UsageClientAuth, flagSignerName, certificatesv1.KubeAPIServerClientSignerName)
+	}
+	allowed := []certificatesv1.KeyUsage{certificatesv1.UsageDigitalSignature, certificatesv1.UsageKeyEncipherment, s.extUsage}
+	for _, u := range keyUsages(usages) {
+		if u == certificatesv1.UsageServerAuth {
+			return fmt.Errorf("signer %q issues %q certificates only, a single csr can't also request %q - use --%s with a custom signer issuing both, or request the serving certificate separately", name, s.extUsage, u, flagSignerName)
+		}
+		if !containsKeyUsage(allowed, u) {
+			return fmt.Errorf("signer %q doesn't issue key usage %q, it allows %q", name, u, allowed)
+		}
+	}
+	return nil
+}
+
+func containsKeyUsage(usages []certificatesv1.KeyUsage, usage certificatesv1.KeyUsage) bool {
+	for _, u := range usages {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterSigners returns the distinct signer names of the csrs in the
+// cluster, in the order they are first seen, failing once timeout passes
+// unless it is zero.
+func clusterSigners(client clientset.Interface, timeout time.Duration) ([]string, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout != 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	var names []string
+	seen := map[string]bool{}
+	options := metav1.ListOptions{Limit: defaultListLimit}
+	for {
+		csrs, err := client.CertificatesV1().
+			CertificateSigningRequests().
+			List(ctx, options)
+		if isTimeout(err) {
+			return nil, fmt.Errorf("no response within --%s %s, the apiserver may be unresponsive", flagDiscoveryTimeout, timeout)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, csr := range csrs.Items {
+			if !seen[csr.Spec.SignerName] {
+				seen[csr.Spec.SignerName] = true
+				names = append(names, csr.Spec.SignerName)
+			}
+		}
+
+		if len(csrs.Continue) == 0 {
+			return names, nil
+		}
+		options.Continue = csrs.Continue
+	}
+}
+
+// validateSigner checks that name is a built-in signer or one already
+// used by a csr in the cluster.
+func validateSigner(client clientset.Interface, name string, timeout time.Duration) error {
+	if isBuiltinSigner(name) {
+		return nil
+	}
+
+	names, err := clusterSigners(client, timeout)
+	if err != nil {
+		return fmt.Errorf("unable to verify signer %q: %v - use --%s to skip the check", name, err, flagAllowUnknownSigner)
+	}
+	for _, n := range names {
+		if n == name {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown signer %q, not a built-in signer nor used by any csr in the cluster - use --%s to skip the check", name, flagAllowUnknownSigner)
+}
+
+type SignersOptions struct {
+	discoveryTimeout time.Duration
+
+	clientSet clientset.Interface
+
+	out io.Writer
+}
+
+func NewCmdCertSigners(configFlags *genericclioptions.ConfigFlags) *cobra.Command {
+	o := SignersOptions{
+		out: os.Stdout,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "signers",
+		Short: "List the built-in signers and the custom signers used by csrs in the cluster.",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdutil.CheckErr(o.Complete(configFlags))
+			cmdutil.CheckErr(o.Validate())
+			cmdutil.CheckErr(o.Run())
+		},
+	}
+
+	addDiscoveryTimeoutFlag(cmd, &o.discoveryTimeout)
+
+	return cmd
+}
+
+func (o *SignersOptions) Complete(configFlags *genericclioptions.ConfigFlags) error {
+	restConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	o.clientSet, err = clientset.NewForConfig(restConfig)
+	return err
+}
+
+func (o *SignersOptions) Validate() error {
+	if o.discoveryTimeout < 0 {
+		return fmt.Errorf("--%s must not be negative", flagDiscoveryTimeout)
+	}
+	return nil
+}
+
+// Run prints the built-in signers and, if allowed to list csrs, the custom
+// signers found in the cluster.
+func (o *SignersOptions) Run() error {
+	signers := append([]signer{}, builtinSigners...)
+
+	names, err := clusterSigners(o.clientSet, o.discoveryTimeout)
+	switch {
+	case apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err):
+		klog.Warningf("unable to list csrs, showing the built-in signers only: %v", err)
+	case err != nil:
+		return err
+	}
+	for _, name := range names {
+		if !isBuiltinSigner(name) {
+			signers = append(signers, signer{name: name, description: customSignerDescription})
+		}
+	}
+
+	w := tabwriter.NewWriter(o.out, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDESCRIPTION")
+	for _, s := range signers {
+		fmt.Fprintf(w, "%s\t%s\n", s.name, s.description)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,152 @@
+package cert
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestValidateSigner(t *testing.T) {
+	client := fake.NewSimpleClientset(&certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "bob:dev"},
+		Spec:       certificatesv1.CertificateSigningRequestSpec{SignerName: "example.com/custom"},
+	})
+
+	var tests = []struct {
+		signerName string
+		err        bool
+	}{
+		{signerName: certificatesv1.KubeAPIServerClientSignerName},
+		{signerName: "example.com/custom"},
+		{signerName: "example.com/typo", err: true},
+	}
+	for _, test := range tests {
+		err := validateSigner(client, test.signerName, 0)
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.signerName, err)
+		}
+	}
+}
+
+func TestValidateSignerTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	client := kubernetes.NewForConfigOrDie(&rest.Config{Host: server.URL})
+
+	err := validateSigner(client, "example.com/custom", 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "--discovery-timeout 10ms") {
+		t.Errorf("got error %v, want a discovery timeout", err)
+	}
+
+	o := SignersOptions{clientSet: client, discoveryTimeout: 10 * time.Millisecond, out: io.Discard}
+	if err := o.Run(); err == nil || !strings.Contains(err.Error(), "--discovery-timeout 10ms") {
+		t.Errorf("signers: got error %v, want a discovery timeout", err)
+	}
+}
+
+func TestClusterSignersPages(t *testing.T) {
+	pages := []*certificatesv1.CertificateSigningRequestList{
+		{
+			ListMeta: metav1.ListMeta{Continue: "page-2"},
+			Items:    []certificatesv1.CertificateSigningRequest{{Spec: certificatesv1.CertificateSigningRequestSpec{SignerName: "example.com/first"}}},
+		},
+		{
+			Items: []certificatesv1.CertificateSigningRequest{{Spec: certificatesv1.CertificateSigningRequestSpec{SignerName: "example.com/second"}}},
+		},
+	}
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		// the fake clientset does not pass the continue token through, so
+		// serve the pages in order
+		page := pages[0]
+		pages = pages[1:]
+		return true, page, nil
+	})
+
+	if err := validateSigner(client, "example.com/second", 0); err != nil {
+		t.Errorf("signer of the second page: %v", err)
+	}
+}
+
+func TestValidateSignerUsages(t *testing.T) {
+	var tests = []struct {
+		signerName string
+		usages     []string
+		err        string
+	}{
+		{signerName: certificatesv1.KubeAPIServerClientSignerName, err: "must include"},
+		{signerName: certificatesv1.KubeAPIServerClientSignerName, usages: []string{"client auth"}},
+		{signerName: certificatesv1.KubeAPIServerClientSignerName, usages: []string{"digital signature", "key encipherment", "client auth"}},
+		{signerName: certificatesv1.KubeAPIServerClientSignerName, usages: []string{"client auth", "server auth"}, err: "--signer-name with a custom signer"},
+		{signerName: certificatesv1.KubeAPIServerClientKubeletSignerName, usages: []string{"client auth", "server auth"}, err: "request the serving certificate separately"},
+		{signerName: certificatesv1.KubeAPIServerClientSignerName, usages: []string{"client auth", "code signing"}, err: "doesn't issue key usage"},
+		{signerName: certificatesv1.KubeletServingSignerName, usages: []string{"client auth", "server auth"}, err: "--signer-name " + certificatesv1.KubeAPIServerClientSignerName},
+		{signerName: "example.com/custom", usages: []string{"client auth", "server auth"}},
+		{signerName: "example.com/custom", usages: []string{"server auth"}, err: "must include"},
+		{signerName: "example.com/custom", usages: []string{"client auth", "client-auth"}, err: "unknown key usage"},
+	}
+	for _, test := range tests {
+		err := validateSignerUsages(test.signerName, test.usages)
+		if len(test.err) == 0 && err != nil {
+			t.Errorf("%s %q: unexpected error %v", test.signerName, test.usages, err)
+		}
+		if len(test.err) != 0 && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s %q: got error %v, want %q", test.signerName, test.usages, err, test.err)
+		}
+	}
+}
+
+func TestRunSigners(t *testing.T) {
+	var tests = []struct {
+		name      string
+		forbidden bool
+		want      []string
+	}{
+		{name: "allowed", want: []string{certificatesv1.KubeAPIServerClientSignerName, "example.com/custom"}},
+		{name: "forbidden", forbidden: true, want: []string{certificatesv1.KubeAPIServerClientSignerName}},
+	}
+	for _, test := range tests {
+		client := fake.NewSimpleClientset(&certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "bob:dev"},
+			Spec:       certificatesv1.CertificateSigningRequestSpec{SignerName: "example.com/custom"},
+		})
+		if test.forbidden {
+			client.PrependReactor("list", "certificatesigningrequests", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, apierrors.NewForbidden(certificatesv1.Resource("certificatesigningrequests"), "", errors.New("no access"))
+			})
+		}
+
+		var out bytes.Buffer
+		o := SignersOptions{clientSet: client, out: &out}
+		if err := o.Run(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for _, name := range test.want {
+			if !strings.Contains(out.String(), name) {
+				t.Errorf("%s: %q not listed in %q", test.name, name, out.String())
+			}
+		}
+		if test.forbidden && strings.Contains(out.String(), "example.com/custom") {
+			t.Errorf("%s: custom signer listed without access", test.name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package cert
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	flagStdinKubeconfig = "stdin-kubeconfig"
+
+	stdinKubeconfigName = "<stdin>"
+)
+
+// stdinConfigAccess serves the kubeconfig read from stdin, which has no file
+// to write back to.
+type stdinConfigAccess struct {
+	config *clientcmdapi.Config
+}
+
+var _ clientcmd.ConfigAccess = stdinConfigAccess{}
+
+func (a stdinConfigAccess) GetLoadingPrecedence() []string {
+	return []string{stdinKubeconfigName}
+}
+
+func (a stdinConfigAccess) GetStartingConfig() (*clientcmdapi.Config, error) {
+	return a.config.DeepCopy(), nil
+}
+
+func (a stdinConfigAccess) GetDefaultFilename() string {
+	return ""
+}
+
+func (a stdinConfigAccess) IsExplicitFile() bool {
+	return false
+}
+
+func (a stdinConfigAccess) GetExplicitFile() string {
+	return ""
+}
+
+// readKubeconfig reads exactly one valid kubeconfig document.
+func readKubeconfig(r io.Reader) (*clientcmdapi.Config, error) {
+	var docs [][]byte
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(doc)) != 0 {
+			docs = append(docs, doc)
+		}
+	}
+	switch len(docs) {
+	case 0:
+		return nil, errors.New("no kubeconfig on stdin")
+	case 1:
+	default:
+		return nil, fmt.Errorf("%d yaml documents on stdin, want a single kubeconfig", len(docs))
+	}
+
+	config, err := clientcmd.Load(docs[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := clientcmd.Validate(*config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,66 @@
+package cert
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const flagSubjectSpec = "subject-spec"
+
+// subjectSpec is the json or yaml --subject-spec file.
+type subjectSpec struct {
+	CommonName         string   `json:"commonName"`
+	Organization       []string `json:"organization,omitempty"`
+	OrganizationalUnit []string `json:"organizationalUnit,omitempty"`
+	Country            []string `json:"country,omitempty"`
+	Locality           []string `json:"locality,omitempty"`
+	Province           []string `json:"province,omitempty"`
+	SerialNumber       string   `json:"serialNumber,omitempty"`
+}
+
+// readSubjectSpec reads the certificate subject used verbatim instead of the
+// one derived from the user and groups, rejecting unknown fields.
+func readSubjectSpec(path string) (*pkix.Name, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var spec subjectSpec
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("--%s %s: %v", flagSubjectSpec, path, err)
+	}
+
+	return &pkix.Name{
+		CommonName:         spec.CommonName,
+		Organization:       spec.Organization,
+		OrganizationalUnit: spec.OrganizationalUnit,
+		Country:            spec.Country,
+		Locality:           spec.Locality,
+		Province:           spec.Province,
+		SerialNumber:       spec.SerialNumber,
+	}, nil
+}
+
+func (o *CertOptions) validateSubjectSpec() error {
+	if len(strings.TrimSpace(o.subjectSpec.CommonName)) == 0 {
+		return fmt.Errorf("--%s: commonName must not be empty", flagSubjectSpec)
+	}
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{len(o.requestFrom) != 0, flagRequestFrom},
+		{len(o.cnTemplate) != 0, flagCNTemplate},
+		{len(o.subjectGroups) != 0, flagSubjectGroup},
+	} {
+		if f.set {
+			return fmt.Errorf("--%s and --%s are mutually exclusive", flagSubjectSpec, f.name)
+		}
+	}
+	return nil
+}
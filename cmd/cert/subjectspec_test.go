@@ -0,0 +1,88 @@
+package cert
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	k8stesting "k8s.io/client-go/testing"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+func TestReadSubjectSpec(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content string
+		err     bool
+		invalid bool
+	}{
+		{name: "yaml", content: "commonName: alice\norganization: [dev]\norganizationalUnit: [platform]\ncountry: [NL]\n"},
+		{name: "json", content: `{"commonName": "alice", "serialNumber": "42"}`},
+		{name: "unknown field", content: "commonName: alice\norg: [dev]\n", err: true},
+		{name: "not a spec", content: "- alice\n", err: true},
+		{name: "no common name", content: "organization: [dev]\n", invalid: true},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "spec.yaml")
+		if err := os.WriteFile(path, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		subject, err := readSubjectSpec(path)
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if err != nil {
+			continue
+		}
+		o := CertOptions{subjectSpec: subject}
+		if err := o.validateSubjectSpec(); test.invalid != (err != nil) {
+			t.Errorf("%s: unexpected validation error %v", test.name, err)
+		}
+	}
+}
+
+func TestRunSubjectSpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.yaml")
+	spec := "commonName: alice@example.com\norganization: [dev]\norganizationalUnit: [platform]\ncountry: [NL]\nlocality: [Amsterdam]\nprovince: [NH]\nserialNumber: \"42\"\n"
+	if err := os.WriteFile(path, []byte(spec), 0600); err != nil {
+		t.Fatal(err)
+	}
+	subject, err := readSubjectSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, client := newTestCertOptions(t)
+	o.subjectSpec = subject
+	o.subjectGroups = []string{"ops"}
+	if err := o.Validate(); err == nil {
+		t.Error("accepted --subject-spec with --subject-group")
+	}
+	o.subjectGroups = nil
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range client.Actions() {
+		if !action.Matches("create", "certificatesigningrequests") {
+			continue
+		}
+		created := action.(k8stesting.CreateAction).GetObject().(*certificatesv1.CertificateSigningRequest)
+		if created.Spec.Username != "alice" || !reflect.DeepEqual(created.Spec.Groups, []string{"dev"}) {
+			t.Errorf("got spec user %q and groups %q, want the flags", created.Spec.Username, created.Spec.Groups)
+		}
+		csr, err := cmdutilpkix.ParseCertificateRequestPem(created.Spec.Request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := csr.Subject.String(), subject.String(); got != want {
+			t.Errorf("got subject %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package cert
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+const (
+	flagSummary = "summary"
+
+	summaryJSON = "json"
+)
+
+type summary struct {
+	CSRName     string   `json:"csrName"`
+	Username    string   `json:"username"`
+	Groups      []string `json:"groups"`
+	ClusterName string   `json:"clusterName"`
+	Server      string   `json:"server"`
+	CertSerial  string   `json:"certSerial"`
+	NotBefore   string   `json:"notBefore"`
+	NotAfter    string   `json:"notAfter"`
+	OutputPath  string   `json:"outputPath"`
+}
+
+func (o *CertOptions) validateSummary() error {
+	switch o.summary {
+	case "":
+		return nil
+	case summaryJSON:
+	default:
+		return fmt.Errorf("--%s must be '%s'", flagSummary, summaryJSON)
+	}
+
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{o.offline, flagOffline},
+		{o.requestOnly, flagRequestOnly},
+		{o.dryRun != dryRunNone, flagDryRun},
+		{o.format == formatRequestJSON, flagFormat + "=" + formatRequestJSON},
+	} {
+		if f.set {
+			return fmt.Errorf("--%s issues no certificate, it cannot be used with --%s", f.name, flagSummary)
+		}
+	}
+	// the summary takes stdout
+	path, err := o.configPath()
+	if err != nil {
+		return err
+	}
+	if path == "<stdout>" {
+		return fmt.Errorf("--%s requires the kubeconfig written to a file with --%s, --%s or --%s", flagSummary, flagOutput, flagOutputDir, flagMerge)
+	}
+	return nil
+}
+
+// printSummary prints the issued certificate and where its kubeconfig was
+// written as a single json object.
+func (o *CertOptions) printSummary(clusterName string, server string, cert []byte) error {
+	certs, err := cmdutilpkix.ParseCertificatesPem(cert)
+	if err != nil {
+		return fmt.Errorf("--%s: %v", flagSummary, err)
+	}
+	outputPath, err := o.configPath()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(summary{
+		CSRName:     o.csrName,
+		Username:    o.userName,
+		Groups:      o.groups,
+		ClusterName: clusterName,
+		Server:      server,
+		CertSerial:  serialHex(certs[0]),
+		NotBefore:   certs[0].NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:    certs[0].NotAfter.UTC().Format(time.RFC3339),
+		OutputPath:  outputPath,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(o.out, "%s\n", content)
+	return err
+}
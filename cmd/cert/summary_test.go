@@ -0,0 +1,85 @@
+package cert
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	cmdutilpkix "github.com/qqbuby/kconfig/cmd/util/pkix"
+)
+
+func TestWriteKubeconfigSummary(t *testing.T) {
+	_, der, err := cmdutilpkix.CreateSelfSignedCertificate("alice", []string{"dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := cmdutilpkix.PemCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o, _ := newTestCertOptions(t)
+	o.summary = summaryJSON
+	var out strings.Builder
+	o.out = &out
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.writeKubeconfig([]byte("key"), cert); err != nil {
+		t.Fatal(err)
+	}
+
+	var got summary
+	if err := json.Unmarshal([]byte(out.String()), &got); err != nil {
+		t.Fatalf("%v: %q", err, out.String())
+	}
+	want := summary{
+		CSRName:     "alice:dev",
+		Username:    "alice",
+		Groups:      []string{"dev"},
+		ClusterName: "local",
+		Server:      "https://127.0.0.1:6443",
+		CertSerial:  "7e5",
+		NotBefore:   got.NotBefore,
+		NotAfter:    got.NotAfter,
+		OutputPath:  o.output,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if len(got.NotBefore) == 0 || len(got.NotAfter) == 0 {
+		t.Errorf("no validity in %+v", got)
+	}
+	if _, err := os.Stat(o.output); err != nil {
+		t.Errorf("kubeconfig not written: %v", err)
+	}
+}
+
+func TestValidateSummary(t *testing.T) {
+	var tests = []struct {
+		name    string
+		summary string
+		output  bool
+		dryRun  string
+		err     bool
+	}{
+		{name: "none", dryRun: dryRunNone},
+		{name: "json", summary: summaryJSON, output: true, dryRun: dryRunNone},
+		{name: "yaml", summary: "yaml", output: true, dryRun: dryRunNone, err: true},
+		{name: "stdout", summary: summaryJSON, dryRun: dryRunNone, err: true},
+		{name: "dry-run", summary: summaryJSON, output: true, dryRun: dryRunClient, err: true},
+	}
+	for _, test := range tests {
+		o, _ := newTestCertOptions(t)
+		o.summary = test.summary
+		o.dryRun = test.dryRun
+		if !test.output {
+			o.output = ""
+		}
+		if err := o.validateSummary(); test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package cert
+
+import (
+	"fmt"
+	"path/filepath"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
+)
+
+const (
+	formatSystemdCreds = "systemd-creds"
+
+	// file names in --output-dir, also the credential ids of LoadCredential=
+	systemdCredKey  = "client.key"
+	systemdCredCert = "client.crt"
+	systemdCredCA   = "ca.crt"
+)
+
+func (o *CertOptions) validateFormatSystemdCreds() error {
+	if len(o.outputDir) == 0 {
+		return fmt.Errorf("--%s=%s requires --%s", flagFormat, o.format, flagOutputDir)
+	}
+	for _, f := range []struct {
+		set  bool
+		name string
+	}{
+		{o.merge, flagMerge},
+		{o.compact, flagCompact},
+		{len(o.output) != 0, flagOutput},
+		{o.referenceFiles, flagReferenceFiles},
+		{o.store != storeKubeconfig, flagStore},
+	} {
+		if f.set {
+			return fmt.Errorf("--%s=%s cannot be used with --%s", flagFormat, o.format, f.name)
+		}
+	}
+	if len(o.requestFrom) != 0 && len(o.keyFile) == 0 {
+		return fmt.Errorf("--%s=%s with --%s requires --%s for the key", flagFormat, o.format, flagRequestFrom, flagKeyFile)
+	}
+	return nil
+}
+
+// writeSystemdCreds writes the key, certificate and certificate authority of
+// the kubeconfig as separate files for LoadCredential= of a systemd unit.
+func writeSystemdCreds(dir string, kubeconfig clientcmdapi.Config) error {
+	ctx := kubeconfig.Contexts[kubeconfig.CurrentContext]
+	authInfo := kubeconfig.AuthInfos[ctx.AuthInfo]
+	cluster := kubeconfig.Clusters[ctx.Cluster]
+
+	err := cmdutil.WriteFile(filepath.Join(dir, systemdCredKey), authInfo.ClientKeyData, keyFileMode)
+	if err != nil {
+		return err
+	}
+	err = cmdutil.WriteFile(filepath.Join(dir, systemdCredCert), authInfo.ClientCertificateData, certFileMode)
+	if err != nil {
+		return err
+	}
+	if len(cluster.CertificateAuthorityData) != 0 {
+		return cmdutil.WriteFile(filepath.Join(dir, systemdCredCA), cluster.CertificateAuthorityData, certFileMode)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunSystemdCreds(t *testing.T) {
+	o, _ := newTestCertOptions(t)
+	o.format = formatSystemdCreds
+	o.output = ""
+	o.outputDir = t.TempDir()
+	if err := o.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := o.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mode := range map[string]os.FileMode{systemdCredKey: keyFileMode, systemdCredCert: certFileMode} {
+		fi, err := os.Stat(filepath.Join(o.outputDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != mode {
+			t.Errorf("%s: got mode %o, want %o", name, fi.Mode().Perm(), mode)
+		}
+	}
+	if cert, _ := os.ReadFile(filepath.Join(o.outputDir, systemdCredCert)); string(cert) != "certificate" {
+		t.Errorf("got certificate %q", cert)
+	}
+	if _, err := os.Stat(filepath.Join(o.outputDir, systemdCredCA)); !os.IsNotExist(err) {
+		t.Errorf("%s written for a cluster without certificate authority: %v", systemdCredCA, err)
+	}
+
+	o.outputDir = ""
+	if err := o.Validate(); err == nil {
+		t.Errorf("expected an error without --%s", flagOutputDir)
+	}
+}
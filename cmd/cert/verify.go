@@ -0,0 +1,94 @@
+package cert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// selfSubjectReviewVersions are the authentication.k8s.io versions serving
+// SelfSubjectReview, newest first. The API is newer than the vendored
+// client-go, so it is called through the raw REST client.
+var selfSubjectReviewVersions = []string{"v1", "v1beta1", "v1alpha1"}
+
+type selfSubjectReview struct {
+	metav1.TypeMeta `json:",inline"`
+	Status          struct {
+		UserInfo authenticationv1.UserInfo `json:"userInfo"`
+	} `json:"status"`
+}
+
+// verifyLogin authenticates against the api server with the given
+// kubeconfig and returns the identity the server attributes to it. The
+// returned user info is nil when the server is too old to report it.
+func verifyLogin(kubeconfig clientcmdapi.Config) (*authenticationv1.UserInfo, error) {
+	config, err := clientcmd.NewDefaultClientConfig(kubeconfig, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := clientset.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	userInfo, err := reviewSelf(client)
+	if err != nil {
+		return nil, fmt.Errorf("verify login: %v", err)
+	}
+	if userInfo != nil {
+		return userInfo, nil
+	}
+
+	// Older servers cannot report the identity, a rules review still
+	// proves that the credentials authenticate.
+	_, err = client.AuthorizationV1().SelfSubjectRulesReviews().Create(context.TODO(), &authorizationv1.SelfSubjectRulesReview{
+		Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: metav1.NamespaceDefault},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("verify login: %v", err)
+	}
+
+	return nil, nil
+}
+
+// reviewSelf returns the identity the api server attributes to the client,
+// or nil when the server is too old to serve SelfSubjectReview.
+func reviewSelf(client clientset.Interface) (*authenticationv1.UserInfo, error) {
+	for _, version := range selfSubjectReviewVersions {
+		body, err := json.Marshal(selfSubjectReview{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: authenticationv1.GroupName + "/" + version,
+				Kind:       "SelfSubjectReview",
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := client.AuthenticationV1().RESTClient().Post().
+			AbsPath("/apis", authenticationv1.GroupName, version, "selfsubjectreviews").
+			Body(body).
+			DoRaw(context.TODO())
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var review selfSubjectReview
+		if err := json.Unmarshal(raw, &review); err != nil {
+			return nil, err
+		}
+		return &review.Status.UserInfo, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,154 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+// waitForCertificate watches the csr until the signer populates its
+// certificate, failing once it is denied or failed, the timeout expires or
+// more than --max-events updates arrived without a certificate.
+func (o *CertOptions) waitForCertificate() (*certificatesv1.CertificateSigningRequest, error) {
+	klog.V(2).InfoS("wait for csr to be issued", "csr", o.csrName, "phase", "wait", "duration", time.Since(o.start))
+	defer o.startProgress(fmt.Sprintf("waiting for csr %q to be issued", o.csrName))()
+
+	ctx := context.Background()
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	events := 0
+	for {
+		if ctx.Err() != nil {
+			return nil, o.waitTimedOut()
+		}
+		// each watch starts from a fresh read, a closed watch may have
+		// missed updates and its resource version may have expired
+		csr, err := o.getCertificateSigningRequest()
+		if err != nil {
+			return nil, err
+		}
+		issued, err := o.certificateIssued(csr)
+		if err != nil || issued {
+			return csr, err
+		}
+
+		w, err := o.clientSet.CertificatesV1().
+			CertificateSigningRequests().
+			Watch(ctx, metav1.ListOptions{
+				FieldSelector:   fields.OneTermEqualSelector("metadata.name", o.csrName).String(),
+				ResourceVersion: csr.ResourceVersion,
+			})
+		if isExpired(err) {
+			klog.V(2).InfoS("csr resource version expired, reading it again", "csr", o.csrName, "resourceVersion", csr.ResourceVersion)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		csr, events, err = o.watchCertificate(ctx, w, events)
+		w.Stop()
+		if err != nil || csr != nil {
+			return csr, err
+		}
+	}
+}
+
+// watchCertificate consumes events until the csr is done, returning it and
+// the number of events seen so far. It returns no csr once the watch closes
+// or its resource version expires.
+func (o *CertOptions) watchCertificate(ctx context.Context, w watch.Interface, events int) (*certificatesv1.CertificateSigningRequest, int, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, events, o.waitTimedOut()
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil, events, nil
+			}
+
+			switch event.Type {
+			case watch.Error:
+				err := apierrors.FromObject(event.Object)
+				if isExpired(err) {
+					klog.V(2).InfoS("csr watch expired, reading the csr again", "csr", o.csrName, "err", err)
+					return nil, events, nil
+				}
+				return nil, events, err
+			case watch.Deleted:
+				return nil, events, fmt.Errorf("csr %q was deleted while waiting for its certificate", o.csrName)
+			}
+
+			csr, ok := event.Object.(*certificatesv1.CertificateSigningRequest)
+			if !ok {
+				continue
+			}
+			events++
+
+			issued, err := o.certificateIssued(csr)
+			if err != nil || issued {
+				return csr, events, err
+			}
+
+			if o.maxEvents > 0 && events >= o.maxEvents {
+				return nil, events, fmt.Errorf("gave up on csr %q after %d updates without a certificate, last conditions: %s", o.csrName, events, formatConditions(csr.Status.Conditions))
+			}
+		}
+	}
+}
+
+func (o *CertOptions) waitTimedOut() error {
+	return fmt.Errorf("timed out after %s waiting for csr %q to be issued", o.timeout, o.csrName)
+}
+
+// isExpired reports whether a watch failed for a resource version the api
+// server no longer serves.
+func isExpired(err error) bool {
+	return apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+}
+
+// certificateIssued reports whether the csr carries its certificate and
+// fails for denied or failed csrs.
+func (o *CertOptions) certificateIssued(csr *certificatesv1.CertificateSigningRequest) (bool, error) {
+	if csr.Status.Certificate != nil {
+		klog.V(2).InfoS("csr issued", "csr", o.csrName, "phase", "issued", "duration", time.Since(o.start))
+		return true, nil
+	}
+
+	for _, c := range csr.Status.Conditions {
+		switch c.Type {
+		case certificatesv1.CertificateDenied:
+			return false, fmt.Errorf("%w: csr %q, reason %q: %s", errCertificateDenied, o.csrName, c.Reason, c.Message)
+		case certificatesv1.CertificateFailed:
+			return false, fmt.Errorf("csr %q failed, reason %q: %s", o.csrName, c.Reason, c.Message)
+		}
+	}
+
+	return false, nil
+}
+
+func formatConditions(conditions []certificatesv1.CertificateSigningRequestCondition) string {
+	if len(conditions) == 0 {
+		return "none"
+	}
+
+	s := ""
+	for i, c := range conditions {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%s=%s (%s)", c.Type, c.Status, c.Reason)
+	}
+	return s
+}
@@ -0,0 +1,129 @@
+package cert
+
+import (
+	"strings"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestWaitForCertificateMaxEvents(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.maxEvents = 5
+
+	pending := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: o.csrName},
+		Status: certificatesv1.CertificateSigningRequestStatus{
+			Conditions: []certificatesv1.CertificateSigningRequestCondition{
+				{Type: certificatesv1.CertificateApproved, Status: corev1.ConditionTrue, Reason: ReasonKconfigCertApprove},
+			},
+		},
+	}
+	if err := client.Tracker().Add(pending); err != nil {
+		t.Fatal(err)
+	}
+
+	w := watch.NewFakeWithChanSize(100, false)
+	client.PrependWatchReactor("certificatesigningrequests", k8stesting.DefaultWatchReactor(w, nil))
+	for i := 0; i < 100; i++ {
+		w.Modify(pending.DeepCopy())
+	}
+
+	_, err := o.waitForCertificate()
+	if err == nil {
+		t.Fatal("expected an error after too many events")
+	}
+
+	if !strings.Contains(err.Error(), "after 5 updates") || !strings.Contains(err.Error(), ReasonKconfigCertApprove) {
+		t.Errorf("error does not describe the events and last conditions: %v", err)
+	}
+}
+
+func TestWaitForCertificateIssued(t *testing.T) {
+	o, client := newTestCertOptions(t)
+	o.maxEvents = 5
+
+	csr := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: o.csrName}}
+	if err := client.Tracker().Add(csr); err != nil {
+		t.Fatal(err)
+	}
+
+	w := watch.NewFakeWithChanSize(2, false)
+	client.PrependWatchReactor("certificatesigningrequests", k8stesting.DefaultWatchReactor(w, nil))
+	w.Modify(csr.DeepCopy())
+	issued := csr.DeepCopy()
+	issued.Status.Certificate = []byte("certificate")
+	w.Modify(issued)
+
+	got, err := o.waitForCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got.Status.Certificate) != "certificate" {
+		t.Errorf("certificate: got %q", got.Status.Certificate)
+	}
+}
+
+func TestWaitForCertificateReconnect(t *testing.T) {
+	var tests = []struct {
+		name  string
+		close func(w *watch.FakeWatcher)
+	}{
+		{name: "closed", close: func(w *watch.FakeWatcher) { w.Stop() }},
+		{
+			name: "expired",
+			close: func(w *watch.FakeWatcher) {
+				status := apierrors.NewResourceExpired("too old resource version").Status()
+				w.Error(&status)
+			},
+		},
+		{
+			name: "gone",
+			close: func(w *watch.FakeWatcher) {
+				status := apierrors.NewGone("too old resource version").Status()
+				w.Error(&status)
+			},
+		},
+	}
+	for _, test := range tests {
+		o, client := newTestCertOptions(t)
+
+		csr := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: o.csrName}}
+		if err := client.Tracker().Add(csr); err != nil {
+			t.Fatal(err)
+		}
+
+		// the certificate is issued while the watch is down, no event
+		// reports it
+		watches := 0
+		client.PrependWatchReactor("certificatesigningrequests", func(action k8stesting.Action) (bool, watch.Interface, error) {
+			watches++
+			w := watch.NewFakeWithChanSize(2, false)
+			w.Modify(csr.DeepCopy())
+			test.close(w)
+			issued := csr.DeepCopy()
+			issued.Status.Certificate = []byte("certificate")
+			if err := client.Tracker().Update(certificatesv1.SchemeGroupVersion.WithResource("certificatesigningrequests"), issued, ""); err != nil {
+				t.Fatal(err)
+			}
+			return true, w, nil
+		})
+
+		got, err := o.waitForCertificate()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if string(got.Status.Certificate) != "certificate" {
+			t.Errorf("%s: certificate: got %q", test.name, got.Status.Certificate)
+		}
+		if watches != 1 {
+			t.Errorf("%s: got %d watches, want 1", test.name, watches)
+		}
+	}
+}
@@ -12,21 +12,31 @@ import (
 	"k8s.io/klog/v2"
 
 	"github.com/qqbuby/kconfig/cmd/cert"
+	cmdutil "github.com/qqbuby/kconfig/cmd/util"
 	"github.com/qqbuby/kconfig/cmd/version"
 )
 
 func NewCmdKonfig() *cobra.Command {
+	var logFormat string
+	logFlags := &flag.FlagSet{}
+	klog.InitFlags(logFlags)
+	verbosity := func() int {
+		return int(logFlags.Lookup("v").Value.(flag.Getter).Get().(klog.Level))
+	}
 	var cmds = &cobra.Command{
-		Use: "kconfig",
+		Use:     "kconfig",
+		Version: version.Get().GitVersion,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return cmdutil.SetLogFormat(logFormat, verbosity())
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
 		},
 	}
 
 	flags := cmds.PersistentFlags()
-	logFlags := &flag.FlagSet{}
-	klog.InitFlags(logFlags)
 	flags.AddGoFlagSet(logFlags)
+	flags.StringVar(&logFormat, "log-format", cmdutil.LogFormatText, "log format - one of 'text' or 'json'")
 
 	var kubeconfig string
 	defaultKubeConfig := ""
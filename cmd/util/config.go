@@ -0,0 +1,74 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultConfigFile returns ~/.kconfig/config.yaml.
+func DefaultConfigFile() string {
+	return filepath.Join(homedir.HomeDir(), ".kconfig", "config.yaml")
+}
+
+// ApplyConfigDefaults sets the flags named in the yaml config file that were
+// not given on the command line, nor through one of the environment
+// variables in env, which maps flag names to their variable. A missing file
+// is no error. List values set repeatable flags once per item and mappings
+// set key=value flags once per entry.
+func ApplyConfigDefaults(flags *pflag.FlagSet, name string, env map[string]string) error {
+	content, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return fmt.Errorf("config file %s: %v", name, err)
+	}
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		flag := flags.Lookup(key)
+		if flag == nil {
+			return fmt.Errorf("config file %s: unknown flag %q", name, key)
+		}
+		if flag.Changed || len(os.Getenv(env[key])) != 0 {
+			continue
+		}
+
+		var values []string
+		switch value := config[key].(type) {
+		case []interface{}:
+			for _, item := range value {
+				values = append(values, fmt.Sprint(item))
+			}
+		case map[string]interface{}:
+			for k, v := range value {
+				values = append(values, fmt.Sprintf("%s=%v", k, v))
+			}
+			sort.Strings(values)
+		default:
+			values = append(values, fmt.Sprint(value))
+		}
+		for _, value := range values {
+			if err := flags.Set(key, value); err != nil {
+				return fmt.Errorf("config file %s: %s: %v", name, key, err)
+			}
+		}
+	}
+	return nil
+}
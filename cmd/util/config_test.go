@@ -0,0 +1,65 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestApplyConfigDefaults(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+key-type: ecdsa
+signer-name: example.com/signer
+creator: team-a
+group: [dev, ops]
+timeout: 5m
+`
+	if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	flags := pflag.NewFlagSet("cert", pflag.ContinueOnError)
+	keyType := flags.String("key-type", "rsa", "")
+	signerName := flags.String("signer-name", "kubernetes.io/kube-apiserver-client", "")
+	creator := flags.String("creator", "kconfig.local.io", "")
+	groups := flags.StringArray("group", nil, "")
+	timeout := flags.Duration("timeout", 0, "")
+	if err := flags.Parse([]string{"--signer-name", "example.com/other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("KCONFIG_CREATOR", "team-b")
+	if err := ApplyConfigDefaults(flags, name, map[string]string{"creator": "KCONFIG_CREATOR"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if *keyType != "ecdsa" {
+		t.Errorf("config file default not applied: key-type %q", *keyType)
+	}
+	if *signerName != "example.com/other" {
+		t.Errorf("explicit flag overridden: signer-name %q", *signerName)
+	}
+	if *creator != "kconfig.local.io" {
+		t.Errorf("environment overridden: creator %q", *creator)
+	}
+	if !reflect.DeepEqual(*groups, []string{"dev", "ops"}) {
+		t.Errorf("list not applied: group %q", *groups)
+	}
+	if timeout.String() != "5m0s" {
+		t.Errorf("timeout: got %s", timeout)
+	}
+
+	if err := os.WriteFile(name, []byte("key-typ: ecdsa\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyConfigDefaults(flags, name, nil); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+	if err := ApplyConfigDefaults(flags, filepath.Join(t.TempDir(), "missing.yaml"), nil); err != nil {
+		t.Errorf("missing config file: %v", err)
+	}
+}
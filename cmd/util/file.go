@@ -0,0 +1,94 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// WriteFile writes data to the named file atomically and sets its mode,
+// also when the file already existed or the umask would have masked the
+// mode. A named pipe or device is written to as is, without truncating it or
+// changing its mode, and a symlink is followed to the file it points to.
+func WriteFile(name string, data []byte, mode os.FileMode) error {
+	fi, err := os.Stat(name)
+	if err == nil && !fi.Mode().IsRegular() {
+		return writePipe(name, data)
+	}
+	if err == nil {
+		if name, err = filepath.EvalSymlinks(name); err != nil {
+			return err
+		}
+	}
+
+	return WriteFileAtomic(name, data, mode)
+}
+
+func writePipe(name string, data []byte) error {
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ParseFileMode parses an octal permission string such as "0600".
+func ParseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode %q, must be octal permissions such as 0600", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+// CheckWritable reports whether the named file can be created or written,
+// without leaving a new file behind. A named pipe or device is not probed,
+// opening it would block for a reader or send it an early EOF.
+func CheckWritable(name string) error {
+	fi, statErr := os.Stat(name)
+	if statErr == nil && !fi.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	if os.IsNotExist(statErr) {
+		return os.Remove(name)
+	}
+	return nil
+}
+
+// writeTemp writes the temporary file of WriteFileAtomic, replaced by tests
+// to fail midway.
+var writeTemp = (*os.File).Write
+
+// WriteFileAtomic writes data to a temporary file next to the named file and
+// renames it into place, so the named file is never left partially written.
+func WriteFileAtomic(name string, data []byte, mode os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = writeTemp(f, data)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,192 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWriteFileNamedPipe(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := syscall.Mkfifo(name, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	read := make(chan []byte)
+	go func() {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Error(err)
+			close(read)
+			return
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Error(err)
+		}
+		read <- data
+	}()
+
+	if err := WriteFile(name, []byte("kubeconfig"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-read; string(data) != "kubeconfig" {
+		t.Errorf("got %q, want %q", data, "kubeconfig")
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeNamedPipe == 0 {
+		t.Error("named pipe replaced by a regular file")
+	}
+}
+
+func TestWriteFileError(t *testing.T) {
+	defer func(write func(*os.File, []byte) (int, error)) { writeTemp = write }(writeTemp)
+	writeTemp = func(f *os.File, data []byte) (int, error) {
+		n, _ := f.Write(data[:len(data)/2])
+		return n, syscall.ENOSPC
+	}
+
+	var tests = []struct {
+		name     string
+		existing string
+	}{
+		{name: "new file"},
+		{name: "existing file", existing: "old kubeconfig"},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "kubeconfig")
+		if len(test.existing) != 0 {
+			if err := os.WriteFile(name, []byte(test.existing), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := WriteFile(name, []byte("new kubeconfig"), 0600); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+
+		data, err := os.ReadFile(name)
+		switch {
+		case len(test.existing) == 0 && !os.IsNotExist(err):
+			t.Errorf("%s: got %q, %v, want no file", test.name, data, err)
+		case len(test.existing) != 0 && string(data) != test.existing:
+			t.Errorf("%s: got %q, want %q", test.name, data, test.existing)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.Name() != "kubeconfig" {
+				t.Errorf("%s: temporary file %s left behind", test.name, entry.Name())
+			}
+		}
+	}
+}
+
+func TestWriteFileSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	link := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(target, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(link, []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "new" {
+		t.Errorf("got %q, want %q", data, "new")
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink replaced by a regular file")
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	fifo := filepath.Join(dir, "audit")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name string
+		path string
+		err  bool
+	}{
+		{name: "new file", path: filepath.Join(dir, "kubeconfig")},
+		{name: "named pipe without reader", path: fifo},
+		{name: "missing directory", path: filepath.Join(dir, "missing", "kubeconfig"), err: true},
+	}
+	for _, test := range tests {
+		done := make(chan error)
+		go func() {
+			done <- CheckWritable(test.path)
+		}()
+
+		var err error
+		select {
+		case err = <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: blocked", test.name)
+		}
+		if test.err != (err != nil) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kubeconfig")); !os.IsNotExist(err) {
+		t.Errorf("probe left the new file behind: %v", err)
+	}
+}
+
+func TestLookupOwner(t *testing.T) {
+	var tests = []struct {
+		owner string
+		group string
+		uid   int
+		gid   int
+		err   bool
+	}{
+		{uid: -1, gid: -1},
+		{owner: "0", group: "0", uid: 0, gid: 0},
+		{owner: "root", uid: 0, gid: -1},
+		{owner: "-1", uid: -1, gid: -1, err: true},
+		{group: "no-such-group-kconfig", uid: -1, gid: -1, err: true},
+	}
+	for _, test := range tests {
+		uid, gid, err := LookupOwner(test.owner, test.group)
+		if test.err != (err != nil) {
+			t.Errorf("%q %q: unexpected error %v", test.owner, test.group, err)
+		}
+		if uid != test.uid || gid != test.gid {
+			t.Errorf("%q %q: got %d %d, want %d %d", test.owner, test.group, uid, gid, test.uid, test.gid)
+		}
+	}
+}
+
+func TestCheckChown(t *testing.T) {
+	if err := CheckChown(os.Geteuid(), os.Getegid()); err != nil {
+		t.Errorf("chown to the own uid and gid: %v", err)
+	}
+	if os.Geteuid() != 0 {
+		if err := CheckChown(0, -1); err == nil {
+			t.Error("expected an error for chown to root without root")
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-logr/logr/funcr"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// logOutput is where the json logs go, swapped by tests.
+var logOutput io.Writer = os.Stderr
+
+// SetLogFormat switches klog to the given output format. The text format
+// is klog's default and leaves the logger untouched. The json logger logs
+// up to verbosity, the -v of klog, which filters before it.
+func SetLogFormat(format string, verbosity int) error {
+	switch format {
+	case LogFormatText:
+		klog.ClearLogger()
+	case LogFormatJSON:
+		klog.SetLogger(funcr.NewJSON(func(obj string) {
+			fmt.Fprintln(logOutput, obj)
+		}, funcr.Options{LogTimestamp: true, Verbosity: verbosity}))
+	default:
+		return fmt.Errorf("--log-format must be '%s' or '%s'", LogFormatText, LogFormatJSON)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+
+	"k8s.io/klog/v2"
+)
+
+func TestSetLogFormatJSONVerbosity(t *testing.T) {
+	var out bytes.Buffer
+	logOutput = &out
+	defer func() {
+		logOutput = os.Stderr
+		klog.ClearLogger()
+	}()
+
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	if err := fs.Set("v", "2"); err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Set("v", "0")
+
+	if err := SetLogFormat(LogFormatJSON, 2); err != nil {
+		t.Fatal(err)
+	}
+	klog.V(2).InfoS("issued", "user", "alice")
+	klog.V(3).InfoS("dropped")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("got %q, want a single json entry: %v", out.String(), err)
+	}
+	if entry["msg"] != "issued" || entry["user"] != "alice" || entry["level"] != float64(2) {
+		t.Errorf("got entry %v", entry)
+	}
+
+	if err := SetLogFormat("xml", 0); err == nil {
+		t.Error("accepted an unknown log format")
+	}
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// LookupOwner resolves a user name or uid and a group name or gid for
+// os.Chown, an empty owner or group resolves to -1 which leaves it as is.
+func LookupOwner(owner string, group string) (int, int, error) {
+	uid, gid := -1, -1
+	if len(owner) != 0 {
+		id := owner
+		if _, err := strconv.Atoi(owner); err != nil {
+			u, err := user.Lookup(owner)
+			if err != nil {
+				return -1, -1, err
+			}
+			id = u.Uid
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil || n < 0 {
+			return -1, -1, fmt.Errorf("invalid uid %q", id)
+		}
+		uid = n
+	}
+	if len(group) != 0 {
+		id := group
+		if _, err := strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return -1, -1, err
+			}
+			id = g.Gid
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil || n < 0 {
+			return -1, -1, fmt.Errorf("invalid gid %q", id)
+		}
+		gid = n
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import (
+	"fmt"
+	"os"
+)
+
+// CheckChown reports whether the process may chown its files to uid and gid,
+// which takes root unless only the group changes to one of its own.
+func CheckChown(uid int, gid int) error {
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	if uid != -1 && uid != os.Geteuid() {
+		return fmt.Errorf("changing the owner to uid %d requires root", uid)
+	}
+	if gid == -1 || gid == os.Getegid() {
+		return nil
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if g == gid {
+			return nil
+		}
+	}
+	return fmt.Errorf("changing the group to gid %d requires root or membership in it", gid)
+}
@@ -0,0 +1,11 @@
+package util
+
+import "errors"
+
+// CheckChown fails, windows has no uid and gid to chown files to.
+func CheckChown(uid int, gid int) error {
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+	return errors.New("changing the owner of files is not supported on windows")
+}
@@ -2,16 +2,40 @@ package pkix
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"io"
 	"math/big"
 	"time"
 )
 
+const (
+	KeyTypeRSA   = "rsa"
+	KeyTypeECDSA = "ecdsa"
+
+	CurveP256 = "P-256"
+	CurveP384 = "P-384"
+	CurveP521 = "P-521"
+
+	KeyFormatPKCS8 = "pkcs8"
+	KeyFormatPKCS1 = "pkcs1"
+	KeyFormatSEC1  = "sec1"
+)
+
+var curves = map[string]elliptic.Curve{
+	CurveP256: elliptic.P256(),
+	CurveP384: elliptic.P384(),
+	CurveP521: elliptic.P521(),
+}
+
 func CreateSelfSignedCertificate(cn string, orgs []string, dnsNames []string) (key *rsa.PrivateKey, certBytes []byte, err error) {
 	certTmpl := &x509.Certificate{
 		SerialNumber: big.NewInt(2021),
@@ -53,24 +77,59 @@ func CreateCertificateRequest(random io.Reader, bits int, cn string, orgs []stri
 		return nil, nil, err
 	}
 
+	csr, err = CreateCertificateRequestWithKey(key, cn, orgs, dnsNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, csr, err
+}
+
+func CreateCertificateRequestWithKey(key crypto.Signer, cn string, orgs []string, dnsNames []string) (csr []byte, err error) {
+	return CreateCertificateRequestWithSubject(key, Subject(cn, orgs), dnsNames)
+}
+
+// CreateCertificateRequestWithSubject creates a csr for the subject as is.
+func CreateCertificateRequestWithSubject(key crypto.Signer, subject pkix.Name, dnsNames []string) (csr []byte, err error) {
 	csrTmpl := x509.CertificateRequest{
-		Subject: pkix.Name{
-			CommonName:   cn,
-			Organization: orgs,
-		},
-		DNSNames:           dnsNames,
-		SignatureAlgorithm: x509.SHA256WithRSA,
+		Subject:  subject,
+		DNSNames: dnsNames,
 	}
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		csrTmpl.SignatureAlgorithm = x509.SHA256WithRSA
+	}
+
+	return x509.CreateCertificateRequest(rand.Reader, &csrTmpl, key)
+}
+
+// Subject returns the subject of a client certificate, kubernetes takes the
+// user name from the common name and the groups from the organizations.
+func Subject(cn string, orgs []string) pkix.Name {
+	return pkix.Name{
+		CommonName:   cn,
+		Organization: orgs,
+	}
+}
 
-	csr, err = x509.CreateCertificateRequest(rand.Reader, &csrTmpl, key)
+func GenerateECDSAKey(curve string) (*ecdsa.PrivateKey, error) {
+	c, err := ParseCurve(curve)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return key, csr, err
+	return ecdsa.GenerateKey(c, rand.Reader)
 }
 
-func PemPkcs8PKey(privateKey *rsa.PrivateKey) ([]byte, error) {
+func ParseCurve(name string) (elliptic.Curve, error) {
+	c, ok := curves[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q, must be one of %s, %s or %s", name, CurveP256, CurveP384, CurveP521)
+	}
+
+	return c, nil
+}
+
+func PemPkcs8PKey(privateKey crypto.PrivateKey) ([]byte, error) {
 	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
 	if err != nil {
 		return nil, err
@@ -89,6 +148,38 @@ func PemPkcs8PKey(privateKey *rsa.PrivateKey) ([]byte, error) {
 	return pemKey.Bytes(), nil
 }
 
+func PemPkcs1PKey(privateKey *rsa.PrivateKey) ([]byte, error) {
+	return pemCertificate(x509.MarshalPKCS1PrivateKey(privateKey), "RSA PRIVATE KEY")
+}
+
+func PemSec1PKey(privateKey *ecdsa.PrivateKey) ([]byte, error) {
+	sec1, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pemCertificate(sec1, "EC PRIVATE KEY")
+}
+
+// PemPKey encodes the private key in the named format, pkcs1 is for rsa and
+// sec1 for ecdsa keys only.
+func PemPKey(privateKey crypto.PrivateKey, format string) ([]byte, error) {
+	switch format {
+	case KeyFormatPKCS8:
+		return PemPkcs8PKey(privateKey)
+	case KeyFormatPKCS1:
+		if key, ok := privateKey.(*rsa.PrivateKey); ok {
+			return PemPkcs1PKey(key)
+		}
+	case KeyFormatSEC1:
+		if key, ok := privateKey.(*ecdsa.PrivateKey); ok {
+			return PemSec1PKey(key)
+		}
+	default:
+		return nil, fmt.Errorf("unknown key format %q", format)
+	}
+	return nil, fmt.Errorf("key format %s does not support %T keys", format, privateKey)
+}
+
 func PemCertificate(cert []byte) ([]byte, error) {
 	return pemCertificate(cert, "CERTIFICATE")
 }
@@ -107,3 +198,94 @@ func pemCertificate(der []byte, typ string) ([]byte, error) {
 
 	return pemCert.Bytes(), nil
 }
+
+func ParsePrivateKeyPem(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return signer, nil
+}
+
+func ParseCertificateRequestPem(data []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, errors.New("no CERTIFICATE REQUEST PEM block found")
+	}
+
+	return x509.ParseCertificateRequest(block.Bytes)
+}
+
+func ParseCertificatesPem(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no CERTIFICATE PEM block found")
+	}
+
+	return certs, nil
+}
+
+func PublicKeyEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
+
+// VerifyCertificateRequestPem checks that the PEM csr is signed by its own
+// public key and that this public key belongs to the PEM private key.
+func VerifyCertificateRequestPem(keyPem []byte, csrPem []byte) error {
+	key, err := ParsePrivateKeyPem(keyPem)
+	if err != nil {
+		return err
+	}
+	csr, err := ParseCertificateRequestPem(csrPem)
+	if err != nil {
+		return err
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("invalid csr signature: %v", err)
+	}
+	if !PublicKeyEqual(key.Public(), csr.PublicKey) {
+		return errors.New("csr public key does not match the private key")
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package pkix
 
 import (
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/x509"
 	"encoding/pem"
 	"reflect"
@@ -116,6 +118,55 @@ func TestCreateDefaultCertificateRequest(t *testing.T) {
 	}
 }
 
+func TestCreateCertificateRequestWithECDSAKey(t *testing.T) {
+	var tests = []struct {
+		curve string
+	}{
+		{curve: CurveP256},
+		{curve: CurveP384},
+		{curve: CurveP521},
+	}
+	for _, test := range tests {
+		key, err := GenerateECDSAKey(test.curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if key.Curve.Params().Name != test.curve {
+			t.Errorf("Curve: got %q, want %q", key.Curve.Params().Name, test.curve)
+		}
+
+		csr, err := CreateCertificateRequestWithKey(key, "local.io", nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		xCsr, err := x509.ParseCertificateRequest(csr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = xCsr.CheckSignature(); err != nil {
+			t.Errorf("invalid signature: %s", err)
+		}
+
+		pub, ok := xCsr.PublicKey.(*ecdsa.PublicKey)
+		if !ok {
+			t.Fatalf("PublicKey: got %T, want *ecdsa.PublicKey", xCsr.PublicKey)
+		}
+
+		if pub.Curve.Params().Name != test.curve {
+			t.Errorf("PublicKey curve: got %q, want %q", pub.Curve.Params().Name, test.curve)
+		}
+	}
+}
+
+func TestGenerateECDSAKeyUnknownCurve(t *testing.T) {
+	if _, err := GenerateECDSAKey("P-224"); err == nil {
+		t.Error("expected an error for an unsupported curve")
+	}
+}
+
 func TestPemCertificateRequest(t *testing.T) {
 	var tests = []struct {
 		typ string
@@ -189,3 +240,148 @@ func TestPemCertificate(t *testing.T) {
 		}
 	}
 }
+
+func TestParsePrivateKeyPem(t *testing.T) {
+	rsaKey, _, err := CreateDefaultCertificateRequest("local.io", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := GenerateECDSAKey(CurveP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		key crypto.Signer
+	}{
+		{key: rsaKey},
+		{key: ecKey},
+	}
+	for _, test := range tests {
+		pemKey, err := PemPkcs8PKey(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := ParsePrivateKeyPem(pemKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !PublicKeyEqual(key.Public(), test.key.Public()) {
+			t.Error("Public Key not matching: invalid private key")
+		}
+	}
+
+	if _, err := ParsePrivateKeyPem([]byte("not a pem")); err == nil {
+		t.Error("expected an error for a non PEM input")
+	}
+}
+
+func TestParseCertificatesPem(t *testing.T) {
+	var bundle []byte
+	for _, cn := range []string{"root.local.io", "intermediate.local.io"} {
+		_, cert, err := CreateSelfSignedCertificate(cn, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pemCert, err := PemCertificate(cert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundle = append(bundle, pemCert...)
+	}
+
+	certs, err := ParseCertificatesPem(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(certs) != 2 {
+		t.Fatalf("certificates: got %d, want 2", len(certs))
+	}
+
+	if certs[1].Subject.CommonName != "intermediate.local.io" {
+		t.Errorf("CommonName: got %q, want %q", certs[1].Subject.CommonName, "intermediate.local.io")
+	}
+}
+
+func TestVerifyCertificateRequestPem(t *testing.T) {
+	key, csr, err := CreateDefaultCertificateRequest("alice", []string{"dev"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPem, err := PemPkcs8PKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csrPem, err := PemCertificateRequest(csr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyCertificateRequestPem(keyPem, csrPem); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	other, err := GenerateECDSAKey(CurveP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPem, err := PemPkcs8PKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyCertificateRequestPem(otherPem, csrPem); err == nil {
+		t.Error("expected an error for a mismatched private key")
+	}
+}
+
+func TestPemPKey(t *testing.T) {
+	rsaKey, _, err := CreateDefaultCertificateRequest("alice", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := GenerateECDSAKey(CurveP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		key       crypto.Signer
+		format    string
+		blockType string
+	}{
+		{key: rsaKey, format: KeyFormatPKCS8, blockType: "PRIVATE KEY"},
+		{key: rsaKey, format: KeyFormatPKCS1, blockType: "RSA PRIVATE KEY"},
+		{key: rsaKey, format: KeyFormatSEC1},
+		{key: ecdsaKey, format: KeyFormatPKCS8, blockType: "PRIVATE KEY"},
+		{key: ecdsaKey, format: KeyFormatSEC1, blockType: "EC PRIVATE KEY"},
+		{key: ecdsaKey, format: KeyFormatPKCS1},
+		{key: ecdsaKey, format: "der"},
+	}
+	for _, test := range tests {
+		data, err := PemPKey(test.key, test.format)
+		if len(test.blockType) == 0 {
+			if err == nil {
+				t.Errorf("%T %s: expected an error", test.key, test.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%T %s: %v", test.key, test.format, err)
+		}
+
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != test.blockType {
+			t.Errorf("%T %s: unexpected PEM block %v", test.key, test.format, block)
+		}
+		parsed, err := ParsePrivateKeyPem(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !PublicKeyEqual(parsed.Public(), test.key.Public()) {
+			t.Errorf("%T %s: key does not round trip", test.key, test.format)
+		}
+	}
+}
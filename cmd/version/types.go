@@ -8,6 +8,8 @@ type Info struct {
 	GoVersion    string `json:"goVersion"`
 	Compiler     string `json:"compiler"`
 	Platform     string `json:"platform"`
+	// ClientGoVersion is the k8s.io/client-go module version built in.
+	ClientGoVersion string `json:"clientGoVersion"`
 }
 
 // String returns info as a human-friendly version string.
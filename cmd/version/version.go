@@ -13,7 +13,6 @@ import (
 	apimachineryversion "k8s.io/apimachinery/pkg/version"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/pkg/version"
 	"k8s.io/client-go/tools/clientcmd"
 
 	cmdutil "github.com/qqbuby/kconfig/cmd/util"
@@ -21,7 +20,7 @@ import (
 
 // Version is a struct for version information
 type Version struct {
-	ClientVersion *apimachineryversion.Info `json:"clientVersion,omitempty" yaml:"clientVersion,omitempty"`
+	ClientVersion *Info                     `json:"clientVersion,omitempty" yaml:"clientVersion,omitempty"`
 	ServerVersion *apimachineryversion.Info `json:"serverVersion,omitempty" yaml:"serverVersion,omitempty"`
 }
 
@@ -95,7 +94,7 @@ func (o *Options) Run() error {
 		versionInfo   Version
 	)
 
-	clientVersion := version.Get()
+	clientVersion := Get()
 	versionInfo.ClientVersion = &clientVersion
 
 	if !o.ClientOnly && o.discoveryClient != nil {